@@ -0,0 +1,83 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// authRequired is the detail of a result that failed for want of
+// credentials, which the results table lists apart from other failures.
+const authRequired = "Auth required"
+
+// sshAgentWarnRepos is how many repositories with SSH remotes a run needs
+// before a missing ssh-agent is warned about.
+const sshAgentWarnRepos = 5
+
+var (
+	// sshAuthFailed matches the output of a command whose SSH key was
+	// refused, or that had no key to offer.
+	sshAuthFailed = regexp.MustCompile(`Permission denied \((publickey|keyboard-interactive)`)
+
+	// httpAuthFailed matches the output of a command whose HTTPS
+	// credentials were refused, or missing with no terminal to ask on.
+	httpAuthFailed = regexp.MustCompile(`(?i)authentication failed for|could not read (username|password)|terminal prompts disabled|HTTP Basic: Access denied|invalid username or password|requested URL returned error: 40[13]`)
+)
+
+// authError is a failure for want of credentials, described by what to do
+// about it.
+type authError struct {
+	guidance string
+}
+
+func (e authError) Error() string {
+	return e.guidance
+}
+
+// authFailure returns an authError for a command that failed in the
+// repository at path because its credentials were refused, judging by the
+// output kept for its result.
+func authFailure(path string, err error) (error, bool) {
+
+	resultsMu.Lock()
+	output := err.Error()
+	if state, ok := repoStates[path]; ok {
+		output += "\n" + state.output.String()
+	}
+	resultsMu.Unlock()
+
+	switch {
+	case sshAuthFailed.MatchString(output) && os.Getenv("SSH_AUTH_SOCK") == "":
+		return authError{"the SSH key was refused and no ssh-agent is running (SSH_AUTH_SOCK is not set); start one with eval $(ssh-agent) and add your key with ssh-add"}, true
+	case sshAuthFailed.MatchString(output):
+		return authError{"the SSH key was refused; check that ssh-add -l lists your key and that it is added to your account on the host"}, true
+	case httpAuthFailed.MatchString(output):
+		return authError{"the HTTPS credentials were refused or are missing; the token may have expired, so update it in your credential helper"}, true
+	}
+	return err, false
+}
+
+// warnSSHAgent warns when a run is about to use SSH remotes in many
+// repositories without an ssh-agent, which makes each connection ask for a
+// passphrase or fail.
+func warnSSHAgent(repos int) {
+	if repos < sshAgentWarnRepos || os.Getenv("SSH_AUTH_SOCK") != "" {
+		return
+	}
+	logger.Warn(fmt.Sprintf("%d repositories use SSH remotes and SSH_AUTH_SOCK is not set; without an ssh-agent every connection needs a key without a passphrase. Start one with eval $(ssh-agent) and ssh-add", repos),
+		"repositories", repos)
+}
@@ -0,0 +1,283 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// branchesCmd represents the branches command
+var branchesCmd = &cobra.Command{
+	Use:   "branches directory",
+	Short: "Report local branches with their age and merge status",
+	Long: `Branches lists the local branches of a repository, or with -r of every
+repository beneath a directory, along with how long ago each was last
+committed to and whether it has been merged into the current branch.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		return runOperation(ctx, args[0], branchReport)
+	},
+}
+
+var branchesCleanCmd = &cobra.Command{
+	Use:   "interactive-clean directory",
+	Short: "Pick local branches to delete from a checklist",
+	Long: `Interactive-clean shows the branch report for each repository as a numbered
+checklist and deletes the branches picked. Merged branches are deleted with
+git branch -d; picking an unmerged branch asks again before it is deleted
+with git branch -D.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if recursive {
+			return walkRepositories(ctx, args[0], func(path string) error {
+				return interactiveClean(ctx, path)
+			})
+		}
+		return interactiveClean(ctx, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(branchesCmd)
+	branchesCmd.AddCommand(branchesCleanCmd)
+	describe(branchesCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Report the branches of every repository beneath ~/src", "got branches -r ~/src"},
+		},
+	})
+	describe(branchesCleanCmd, commandInfo{
+		Mutating:    true,
+		Destructive: true,
+		Examples: []example{
+			{"Pick branches to delete in every repository beneath ~/src", "got branches interactive-clean -r ~/src"},
+		},
+	})
+
+	branchesCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "r", false, "Recursively report on subdirectories listed")
+}
+
+// localBranch describes a branch other than the one checked out.
+type localBranch struct {
+	name     string
+	lastUsed time.Time
+	merged   bool
+	upstream string
+	gone     bool
+}
+
+// localBranches returns the local branches of the repository at path, minus
+// the current branch, oldest first.
+func localBranches(ctx context.Context, path string) ([]localBranch, error) {
+
+	if !isRepository(path) {
+		return nil, errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	current, _ := gitOutput(ctx, path, "symbolic-ref", "--short", "HEAD")
+
+	out, err := gitOutput(ctx, path, "for-each-ref", "--sort=committerdate",
+		"--format=%(refname:short)\t%(committerdate:unix)\t%(upstream:short)\t%(upstream:track)", "refs/heads")
+	if err != nil {
+		return nil, errors.Wrapf(err, "[%s] unable to list branches", path)
+	}
+
+	mergedOut, err := gitOutput(ctx, path, "branch", "--format=%(refname:short)", "--merged", "HEAD")
+	if err != nil {
+		return nil, errors.Wrapf(err, "[%s] unable to list merged branches", path)
+	}
+	merged := map[string]bool{}
+	for _, name := range strings.Split(mergedOut, "\n") {
+		merged[name] = true
+	}
+
+	var branches []localBranch
+	for _, line := range strings.Split(out, "\n") {
+		// Trailing empty fields of the last line are lost to trimming.
+		fields := append(strings.Split(line, "\t"), "", "", "")
+		if fields[0] == "" || fields[0] == current {
+			continue
+		}
+		secs, _ := strconv.ParseInt(fields[1], 10, 64)
+		branches = append(branches, localBranch{
+			name:     fields[0],
+			lastUsed: time.Unix(secs, 0),
+			merged:   merged[fields[0]],
+			upstream: fields[2],
+			gone:     fields[3] == "[gone]",
+		})
+	}
+
+	return branches, nil
+}
+
+func (b localBranch) describe() string {
+	state := "unmerged"
+	if b.merged {
+		state = "merged"
+	}
+	if b.gone {
+		state += ", upstream gone"
+	}
+	return fmt.Sprintf("%-40s %-10s %s", b.name, age(b.lastUsed), state)
+}
+
+func branchReport(ctx context.Context, path string) error {
+
+	branches, err := localBranches(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if len(branches) == 0 {
+		return nil
+	}
+
+	out := repoOutput(path)
+	fmt.Fprintf(out, "[%s]\n", path)
+	for _, b := range branches {
+		fmt.Fprintf(out, "  %s\n", b.describe())
+	}
+	return nil
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func interactiveClean(ctx context.Context, path string) error {
+
+	branches, err := localBranches(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if len(branches) == 0 {
+		return nil
+	}
+
+	fmt.Printf("[%s]\n", path)
+	for i, b := range branches {
+		fmt.Printf("  %2d) %s\n", i+1, b.describe())
+	}
+
+	answer := prompt("Delete which branches? (e.g. 1 3-5, m for all merged, enter to skip): ")
+	picked, err := parseSelection(answer, branches)
+	if err != nil {
+		repoSkipped(path, "%v", err)
+		return nil
+	}
+
+	for _, b := range picked {
+		if err := guardProtected(b.name); err != nil {
+			repoFailed(path, err)
+			continue
+		}
+		flag := "-d"
+		if !b.merged {
+			if prompt(fmt.Sprintf("  %s is not merged and its commits may be lost. Delete anyway? [y/N] ", b.name)) != "y" {
+				continue
+			}
+			flag = "-D"
+		}
+		if out, err := gitCommand(ctx, path, "branch", flag, b.name).CombinedOutput(); err != nil {
+			repoFailed(path, errors.Errorf("deleting %s: %s", b.name, strings.TrimSpace(string(out))))
+		} else {
+			repoSucceeded(path, "Deleted %s", b.name)
+		}
+	}
+
+	return nil
+}
+
+// parseSelection turns an answer such as "1 3-5" or "m" into the branches
+// it names.
+func parseSelection(answer string, branches []localBranch) ([]localBranch, error) {
+
+	var picked []localBranch
+	seen := map[int]bool{}
+	add := func(i int) {
+		if !seen[i] {
+			seen[i] = true
+			picked = append(picked, branches[i])
+		}
+	}
+
+	for _, field := range strings.Fields(strings.Replace(answer, ",", " ", -1)) {
+		if field == "m" {
+			for i, b := range branches {
+				if b.merged {
+					add(i)
+				}
+			}
+			continue
+		}
+
+		lo, hi := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			lo, hi = field[:i], field[i+1:]
+		}
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, errors.Errorf("invalid selection [%s]", field)
+		}
+		to, err := strconv.Atoi(hi)
+		if err != nil || from < 1 || to > len(branches) || from > to {
+			return nil, errors.Errorf("invalid selection [%s]", field)
+		}
+		for i := from; i <= to; i++ {
+			add(i - 1)
+		}
+	}
+
+	return picked, nil
+}
+
+// prompt asks a question on stdout and returns the trimmed answer.
+func prompt(question string) string {
+	fmt.Print(question)
+	answer, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// age renders how long ago t was in days, weeks or months.
+func age(t time.Time) string {
+	days := int(time.Since(t).Hours() / 24)
+	switch {
+	case days < 1:
+		return "today"
+	case days < 14:
+		return fmt.Sprintf("%dd ago", days)
+	case days < 60:
+		return fmt.Sprintf("%dw ago", days/7)
+	default:
+		return fmt.Sprintf("%dmo ago", days/30)
+	}
+}
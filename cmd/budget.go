@@ -0,0 +1,147 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+)
+
+// timeBudget, set by --time-budget, is how long a recursive run may keep
+// starting repositories. Zero means no limit.
+var timeBudget time.Duration
+
+// remainingRun is what a run with a time budget left undone, as kept for
+// got retry --remaining.
+type remainingRun struct {
+	Operation string    `json:"operation"`
+	Paths     []string  `json:"paths"`
+	Recorded  time.Time `json:"recorded"`
+}
+
+// byPriority wraps discover so that repositories are handed on most stale
+// first, going by when they were last fetched, so that a run cut short by
+// its budget has brought the oldest ones up to date. Repositories that
+// have never been fetched come first of all.
+func byPriority(discover func(fn func(path string) error) error) func(fn func(path string) error) error {
+	return func(fn func(path string) error) error {
+
+		var paths []string
+		if err := discover(func(path string) error {
+			paths = append(paths, path)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		fetched := map[string]time.Time{}
+		for _, path := range paths {
+			fetched[path] = lastFetched(path)
+		}
+		sort.SliceStable(paths, func(i, j int) bool { return fetched[paths[i]].Before(fetched[paths[j]]) })
+
+		for _, path := range paths {
+			if err := fn(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// lastFetched returns when the repository at path was last fetched, or the
+// zero time if it never has been.
+func lastFetched(path string) time.Time {
+	dir, err := git.GitDir(path)
+	if err != nil {
+		dir = path
+	}
+	if info, err := os.Stat(filepath.Join(dir, "FETCH_HEAD")); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// remainingFile is where the repositories a run left undone are kept.
+func remainingFile() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "remaining.json"), nil
+}
+
+// recordRemaining keeps the repositories a budgeted run didn't get to for
+// got retry --remaining, or clears the record when it got to them all.
+func recordRemaining(paths []string) {
+
+	name, err := remainingFile()
+	if err != nil {
+		logger.Warn("unable to record the repositories left: " + err.Error())
+		return
+	}
+
+	if len(paths) == 0 {
+		os.Remove(name)
+		return
+	}
+
+	for i, path := range paths {
+		paths[i] = absPath(path)
+	}
+	data, err := json.MarshalIndent(remainingRun{Operation: operation, Paths: paths, Recorded: time.Now()}, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err == nil {
+			err = ioutil.WriteFile(name, append(data, '\n'), 0644)
+		}
+	}
+	if err != nil {
+		logger.Warn("unable to record the repositories left: " + err.Error())
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Time budget of %s used up with %d repositories left; continue with got retry --remaining", timeBudget, len(paths)),
+		"budget", timeBudget.String(), "remaining", len(paths))
+}
+
+// loadRemaining returns what the last budgeted run left undone, or nil if
+// it finished.
+func loadRemaining() (*remainingRun, error) {
+
+	name, err := remainingFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to read [%s]", name)
+	}
+
+	var r remainingRun
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse [%s]", name)
+	}
+	return &r, nil
+}
@@ -0,0 +1,152 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const (
+	// maxRepoOutput caps how much output is kept for a single repository.
+	maxRepoOutput = 256 << 10
+
+	// maxMemoryOutput is how much output is held in memory before the
+	// buffer spills over into a temporary file.
+	maxMemoryOutput = 8 << 20
+)
+
+// outputBuffer collects output for later display without letting it grow
+// without bound. The output of each repository, written through forRepo, is
+// truncated past maxRepoOutput, and once maxMemoryOutput bytes are held the
+// rest goes to a temporary file. Repositories are tracked apart, as with
+// --jobs several write at once.
+type outputBuffer struct {
+	mu    sync.Mutex
+	mem   bytes.Buffer
+	spill *os.File
+	repos map[string]*repoOutputCount
+}
+
+// repoOutputCount is how much output of a repository was kept and dropped.
+type repoOutputCount struct {
+	kept    int
+	dropped int
+}
+
+// Write buffers output that belongs to no repository in particular, such
+// as log lines, in full.
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(p), b.write(p)
+}
+
+// forRepo returns a writer for the output of the repository at path. It
+// never reports a short write, even when it discards output, so that a
+// chatty git command isn't failed on our account.
+func (b *outputBuffer) forRepo(path string) io.Writer {
+	return repoOutputWriter{b, path}
+}
+
+type repoOutputWriter struct {
+	b    *outputBuffer
+	path string
+}
+
+func (w repoOutputWriter) Write(p []byte) (int, error) {
+	b := w.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.repos == nil {
+		b.repos = map[string]*repoOutputCount{}
+	}
+	c, ok := b.repos[w.path]
+	if !ok {
+		c = &repoOutputCount{}
+		b.repos[w.path] = c
+	}
+
+	n := len(p)
+	if keep := maxRepoOutput - c.kept; keep < len(p) {
+		if keep < 0 {
+			keep = 0
+		}
+		c.dropped += len(p) - keep
+		p = p[:keep]
+	}
+	c.kept += len(p)
+
+	return n, b.write(p)
+}
+
+func (b *outputBuffer) write(p []byte) error {
+	if b.spill == nil && b.mem.Len()+len(p) > maxMemoryOutput {
+		f, err := ioutil.TempFile("", "got-output-")
+		if err != nil {
+			// Keep going in memory rather than lose output.
+			_, err = b.mem.Write(p)
+			return err
+		}
+		os.Remove(f.Name())
+		b.spill = f
+	}
+
+	if b.spill != nil {
+		_, err := b.spill.Write(p)
+		return err
+	}
+	_, err := b.mem.Write(p)
+	return err
+}
+
+// endRepo closes off the output of the repository at path, noting how much
+// of it was cut.
+func (b *outputBuffer) endRepo(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.repos[path]; ok && c.dropped > 0 {
+		b.write([]byte(fmt.Sprintf("... [output of %s truncated, %s omitted]\n", displayName(path), formatBytes(int64(c.dropped)))))
+	}
+	delete(b.repos, path)
+}
+
+// WriteTo copies everything buffered so far to w and empties the buffer.
+func (b *outputBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n, err := b.mem.WriteTo(w)
+	if err != nil || b.spill == nil {
+		return n, err
+	}
+
+	defer func() {
+		b.spill.Close()
+		b.spill = nil
+	}()
+
+	if _, err := b.spill.Seek(0, io.SeekStart); err != nil {
+		return n, err
+	}
+	m, err := io.Copy(w, b.spill)
+	return n + m, err
+}
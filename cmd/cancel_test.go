@@ -0,0 +1,197 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// initRepos creates n empty git repositories beneath a temporary directory
+// and returns the directory.
+func initRepos(t *testing.T, n int) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	for i := 0; i < n; i++ {
+		path := filepath.Join(root, string(rune('a'+i)))
+		if out, err := exec.Command("git", "init", "-q", path).CombinedOutput(); err != nil {
+			t.Fatalf("git init %s: %v: %s", path, err, out)
+		}
+	}
+	return root
+}
+
+// withInterrupt runs fn with a context cancelled by SIGINT, as Execute
+// gives commands. fn is handed interrupt, which sends the process SIGINT the
+// first time it is called and returns once the signal has been delivered.
+func withInterrupt(t *testing.T, fn func(ctx context.Context, interrupt func()) error) (context.Context, error) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGINT can't be sent to the process on Windows")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var once sync.Once
+	interrupt := func() {
+		once.Do(func() {
+			p, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = p.Signal(os.Interrupt)
+			}
+			if err != nil {
+				t.Errorf("unable to send SIGINT: %v", err)
+				return
+			}
+			<-ctx.Done()
+		})
+	}
+
+	err := fn(ctx, interrupt)
+	return ctx, err
+}
+
+// withJobs sets --jobs for the rest of the test.
+func withJobs(t *testing.T, n int) {
+	saved := jobs
+	jobs = n
+	t.Cleanup(func() { jobs = saved })
+}
+
+// assertCleanedUp fails t if a repository beneath root is still locked, or
+// the output is still being held back for a progress line.
+func assertCleanedUp(t *testing.T, root string) {
+	t.Helper()
+	locks, _ := filepath.Glob(filepath.Join(root, "*", ".git", lockFileName+"*"))
+	for _, lock := range locks {
+		t.Errorf("lock left behind: %s", lock)
+	}
+	if progressActive {
+		t.Error("progress line still active")
+	}
+}
+
+func TestInterruptDuringDiscovery(t *testing.T) {
+	withJobs(t, 1)
+	root := initRepos(t, 5)
+
+	var ran int32
+	ctx, err := withInterrupt(t, func(ctx context.Context, interrupt func()) error {
+		found := 0
+		discover := func(fn func(path string) error) error {
+			return walkRepositories(ctx, root, func(path string) error {
+				if found++; found == 3 {
+					interrupt()
+				}
+				return fn(path)
+			})
+		}
+		return walkFound(ctx, discover, func(ctx context.Context, path string) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	})
+
+	if ctx.Err() == nil {
+		t.Fatal("context not cancelled")
+	}
+	if err == nil {
+		t.Error("walk finished without an error")
+	}
+	if n := atomic.LoadInt32(&ran); n >= 5 {
+		t.Errorf("ran in %d repositories, want fewer than 5", n)
+	}
+	assertCleanedUp(t, root)
+}
+
+func TestInterruptDuringGitCommand(t *testing.T) {
+	withJobs(t, 2)
+	root := initRepos(t, 2)
+
+	started := time.Now()
+	ctx, err := withInterrupt(t, func(ctx context.Context, interrupt func()) error {
+		return walkDirectories(ctx, root, func(ctx context.Context, path string) error {
+			marker := filepath.Join(path, ".git", "hanging")
+			go func() {
+				for {
+					if _, err := os.Stat(marker); err == nil {
+						interrupt()
+						return
+					}
+					select {
+					case <-time.After(10 * time.Millisecond):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			args := []string{"-c", "alias.hang=!touch " + shellQuote(filepath.ToSlash(marker)) + " && sleep 30", "hang"}
+			if err := runner.Run(ctx, path, nil, args...); err != nil && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return nil
+		})
+	})
+
+	if ctx.Err() == nil {
+		t.Fatal("context not cancelled")
+	}
+	if err != context.Canceled {
+		t.Errorf("walk returned %v, want %v", err, context.Canceled)
+	}
+	if hung, _ := filepath.Glob(filepath.Join(root, "*", ".git", "hanging")); len(hung) == 0 {
+		t.Error("interrupted before any git command started")
+	}
+	// The git commands are killed rather than waited for; WaitDelay lets
+	// their helpers hold on for a second at most.
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("walk took %s after the interrupt", elapsed)
+	}
+	assertCleanedUp(t, root)
+}
+
+func TestInterruptBetweenRepositories(t *testing.T) {
+	withJobs(t, 1)
+	root := initRepos(t, 4)
+
+	var ran int32
+	ctx, _ := withInterrupt(t, func(ctx context.Context, interrupt func()) error {
+		return walkDirectories(ctx, root, func(ctx context.Context, path string) error {
+			atomic.AddInt32(&ran, 1)
+			interrupt()
+			return nil
+		})
+	})
+
+	if ctx.Err() == nil {
+		t.Fatal("context not cancelled")
+	}
+	if n := atomic.LoadInt32(&ran); n != 1 {
+		t.Errorf("ran in %d repositories, want 1", n)
+	}
+	assertCleanedUp(t, root)
+}
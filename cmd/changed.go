@@ -0,0 +1,105 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// changedSinceFlag is set by --changed-since, which limits a run to the
+	// repositories whose upstream received new commits within a window or
+	// since a ref.
+	changedSinceFlag string
+
+	changedWindow time.Duration
+	changedRef    string
+)
+
+// initChangedSince reads --changed-since as a duration, with d and w
+// accepted for days and weeks, and otherwise as a ref.
+func initChangedSince() {
+	changedWindow, changedRef = 0, ""
+	if changedSinceFlag == "" {
+		return
+	}
+	if d, ok := parseWindow(changedSinceFlag); ok {
+		changedWindow = d
+	} else {
+		changedRef = changedSinceFlag
+	}
+}
+
+// parseWindow parses durations such as 36h, 7d or 2w.
+func parseWindow(s string) (time.Duration, bool) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) && n > 0 {
+			return time.Duration(n) * unit, true
+		}
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d > 0
+}
+
+// filteringChanged reports whether --changed-since is limiting the run.
+func filteringChanged() bool {
+	return changedWindow > 0 || changedRef != ""
+}
+
+// upstreamChanged reports whether the upstream of the current branch in the
+// repository at path received new commits in the --changed-since window,
+// going by the reflog that fetch keeps for remote-tracking branches, or has
+// commits that the --changed-since ref does not. A ref the repository lacks
+// counts as a change.
+func upstreamChanged(ctx context.Context, path string) bool {
+
+	upstream, err := gitOutput(ctx, path, "rev-parse", "--symbolic-full-name", "@{u}")
+	if err != nil || upstream == "" {
+		return false
+	}
+
+	if changedRef != "" {
+		if _, err := gitOutput(ctx, path, "rev-parse", "-q", "--verify", changedRef+"^{commit}"); err != nil {
+			return true
+		}
+		count, err := gitOutput(ctx, path, "rev-list", "--count", changedRef+".."+upstream)
+		return err == nil && count != "0"
+	}
+
+	cutoff := time.Now().Add(-changedWindow).Unix()
+	if out, err := gitOutput(ctx, path, "reflog", "show", "--date=unix", "--format=%gd", upstream); err == nil && out != "" {
+		for _, entry := range strings.Split(out, "\n") {
+			i, j := strings.LastIndex(entry, "@{"), strings.LastIndex(entry, "}")
+			if i < 0 || j < i {
+				continue
+			}
+			if t, err := strconv.ParseInt(entry[i+2:j], 10, 64); err == nil && t >= cutoff {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without a reflog, the upstream's newest commit is the best guess.
+	out, err := gitOutput(ctx, path, "log", "-1", "--format=%ct", upstream)
+	if err != nil {
+		return false
+	}
+	t, err := strconv.ParseInt(out, 10, 64)
+	return err == nil && t >= cutoff
+}
@@ -0,0 +1,118 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkoutTag    string
+	checkoutDetach bool
+)
+
+// checkoutCmd represents the checkout command
+var checkoutCmd = &cobra.Command{
+	Use:   "checkout directory --tag tag --detach",
+	Short: "Pin repositories to a tag with a detached HEAD",
+	Long: `Checkout switches a repository, or with -r every repository beneath a
+directory, to a detached HEAD at the given tag, so that a multi-repository
+workspace can be pinned to a release in one go.
+
+Every repository is checked before anything is switched: if any of them
+lacks the tag or has uncommitted changes, nothing is changed.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if checkoutTag == "" {
+			return usageErrorf("--tag is required")
+		}
+		if !checkoutDetach {
+			return usageErrorf("checking out a tag requires --detach")
+		}
+		if err := verifyTag(ctx, args[0], checkoutTag); err != nil {
+			return err
+		}
+		return runOperation(ctx, args[0], checkout)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(checkoutCmd)
+	describe(checkoutCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Pin every repository beneath ~/src to the v2.3.1 release", "got checkout -r --tag v2.3.1 --detach ~/src"},
+		},
+	})
+
+	checkoutCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively check out subdirectories listed")
+	checkoutCmd.Flags().StringVar(&checkoutTag, "tag", "", "Tag to check out")
+	checkoutCmd.RegisterFlagCompletionFunc("tag", completeCommonTag)
+	checkoutCmd.Flags().BoolVar(&checkoutDetach, "detach", false, "Detach HEAD at the tag")
+}
+
+// verifyTag checks that every repository the checkout would touch has tag
+// and a clean working tree, listing all that don't.
+func verifyTag(ctx context.Context, root, tag string) error {
+
+	var problems []string
+	check := func(path string) error {
+		if _, err := gitOutput(ctx, path, "rev-parse", "-q", "--verify", "refs/tags/"+tag+"^{commit}"); err != nil {
+			problems = append(problems, fmt.Sprintf("[%s] has no tag %s", path, tag))
+		} else if changes, _ := gitOutput(ctx, path, "status", "--porcelain", "--untracked-files=no"); changes != "" {
+			problems = append(problems, fmt.Sprintf("[%s] has uncommitted changes", path))
+		}
+		return nil
+	}
+
+	if recursive {
+		if err := walkRepositories(ctx, root, check); err != nil {
+			return err
+		}
+	} else if !isRepository(root) {
+		return errors.Errorf("[%s] is not a git repository", root)
+	} else {
+		check(root)
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("not checking out %s:\n  %s", tag, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func checkout(ctx context.Context, path string) error {
+
+	if err := runner.Run(ctx, path, repoCapture(path), "switch", "--detach", "refs/tags/"+checkoutTag); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, err)
+	} else {
+		repoSucceeded(path, "Detached at %s", checkoutTag)
+	}
+
+	return nil
+}
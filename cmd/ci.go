@@ -0,0 +1,335 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	ciSince   string
+	ciCommand string
+	ciJobs    int
+)
+
+// ciCmd represents the ci command
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Run checks across the repositories of a workspace",
+}
+
+// ciRunCmd represents the ci run command
+var ciRunCmd = &cobra.Command{
+	Use:   "run directory",
+	Short: "Run a check command in every repository that changed",
+	Long: `Run runs a check command, such as a linter or the tests, in every repository
+beneath a directory and reports which passed. The command comes from --command
+or from ci.command in the config file and is run by the shell:
+
+  ci:
+    command: make lint test
+
+With --since only repositories whose files differ from that ref, committed or
+not, are checked; those without the ref are checked anyway.
+
+Repositories are checked in dependency order, as declared by go.mod and
+package.json files, with up to --jobs checks at a time. A repository whose
+dependency failed its check is skipped.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		command := ciCommand
+		if command == "" {
+			command = viper.GetString("ci.command")
+		}
+		if command == "" {
+			return usageErrorf("no check command: give --command or set ci.command in the config file")
+		}
+		if ciJobs < 1 {
+			return usageErrorf("--jobs must be at least 1")
+		}
+
+		// Checks always cover the whole workspace beneath the directory.
+		operation = "ci"
+		recursive = true
+		return ciRun(ctx, args[0], command)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciRunCmd)
+	describe(ciCmd, commandInfo{
+		Mutating: false,
+	})
+	describe(ciRunCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Test every repository changed since main, four at a time", "got ci run --since main --jobs 4 --command 'make test' ~/src"},
+		},
+	})
+
+	ciRunCmd.Flags().StringVar(&ciSince, "since", "", "Only check repositories that changed since this ref")
+	ciRunCmd.Flags().StringVar(&ciCommand, "command", "", "Check command to run, instead of ci.command from the config")
+	ciRunCmd.Flags().IntVarP(&ciJobs, "jobs", "j", runtime.NumCPU(), "Number of checks to run at once")
+}
+
+// ciRepo is a repository taking part in a ci run.
+type ciRepo struct {
+	path     string
+	provides []string
+	requires []string
+	deps     []*ciRepo
+	done     chan struct{}
+	failed   bool
+}
+
+func ciRun(ctx context.Context, root, command string) error {
+
+	started := time.Now()
+
+	var repos []*ciRepo
+	err := walkRepositories(ctx, root, func(path string) error {
+		if ciSince != "" && !changedSince(ctx, path, ciSince) {
+			return nil
+		}
+		if filteringChanged() && !upstreamChanged(ctx, path) {
+			return nil
+		}
+		provides, requires := repoPackages(path)
+		repos = append(repos, &ciRepo{path: path, provides: provides, requires: requires, done: make(chan struct{})})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := linkDependencies(repos); err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		if !machineOutput() {
+			message := "No repositories found"
+			if ciSince != "" {
+				message = "No repositories changed since " + ciSince
+			}
+			logger.Info(message)
+		}
+		return nil
+	}
+
+	var (
+		wg    sync.WaitGroup
+		outMu sync.Mutex
+		slots = make(chan struct{}, ciJobs)
+	)
+	for _, r := range repos {
+		wg.Add(1)
+		go func(r *ciRepo) {
+			defer wg.Done()
+			defer close(r.done)
+
+			for _, dep := range r.deps {
+				<-dep.done
+				if dep.failed {
+					r.failed = true
+					repoSkipped(r.path, "Skipped (dependency [%s] failed)", dep.path)
+					return
+				}
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				r.failed = true
+				return
+			}
+			defer func() { <-slots }()
+
+			startRepo(r.path)
+			defer endRepo(r.path)
+
+			var out bytes.Buffer
+			if err := runCheck(ctx, r.path, command, io.MultiWriter(&out, captureWriter(r.path))); err != nil {
+				r.failed = true
+				if ctx.Err() != nil {
+					return
+				}
+				repoFailed(r.path, err)
+				if !machineOutput() {
+					outMu.Lock()
+					repoConsoleOutput(r.path).Write(out.Bytes())
+					outMu.Unlock()
+				}
+				return
+			}
+			repoSucceeded(r.path, "Passed")
+		}(r)
+	}
+	wg.Wait()
+
+	finishRun(time.Since(started))
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if n := failureCount(); n > 0 {
+		return repositoriesFailed("%d of %d checks failed", n, len(repos))
+	}
+	return nil
+}
+
+// runCheck runs command with the shell in the repository at path.
+func runCheck(ctx context.Context, path, command string, out io.Writer) error {
+	cmd := shellCommand(ctx, path, command)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// changedSince reports whether the files of the repository at path differ
+// from ref, counting uncommitted changes and untracked files. A repository
+// without ref counts as changed.
+func changedSince(ctx context.Context, path, ref string) bool {
+	if _, err := gitOutput(ctx, path, "rev-parse", "-q", "--verify", ref+"^{commit}"); err != nil {
+		return true
+	}
+	if _, err := gitOutput(ctx, path, "diff", "--quiet", ref, "--"); err != nil {
+		return true
+	}
+	untracked, _ := gitOutput(ctx, path, "ls-files", "--others", "--exclude-standard")
+	return untracked != ""
+}
+
+// repoPackages returns the Go modules and npm packages the repository at
+// path provides and those it requires.
+func repoPackages(path string) (provides, requires []string) {
+
+	if lines, err := readLines(filepath.Join(path, "go.mod")); err == nil {
+		block := false
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) >= 2 && fields[0] == "module":
+				provides = append(provides, strings.Trim(fields[1], `"`))
+			case len(fields) >= 2 && fields[0] == "require" && fields[1] == "(":
+				block = true
+			case block && fields[0] == ")":
+				block = false
+			case block && len(fields) >= 1:
+				requires = append(requires, fields[0])
+			case len(fields) >= 2 && fields[0] == "require":
+				requires = append(requires, fields[1])
+			}
+		}
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(path, "package.json")); err == nil {
+		var pkg struct {
+			Name            string            `json:"name"`
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			if pkg.Name != "" {
+				provides = append(provides, pkg.Name)
+			}
+			for dep := range pkg.Dependencies {
+				requires = append(requires, dep)
+			}
+			for dep := range pkg.DevDependencies {
+				requires = append(requires, dep)
+			}
+		}
+	}
+
+	return provides, requires
+}
+
+// linkDependencies points each repository at the others in repos that
+// provide what it requires, and rejects dependency cycles.
+func linkDependencies(repos []*ciRepo) error {
+
+	providers := map[string]*ciRepo{}
+	for _, r := range repos {
+		for _, p := range r.provides {
+			providers[p] = r
+		}
+	}
+	for _, r := range repos {
+		for _, req := range r.requires {
+			if dep, ok := providers[req]; ok && dep != r && !containsRepo(r.deps, dep) {
+				r.deps = append(r.deps, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		finished
+	)
+	state := map[*ciRepo]int{}
+	var visit func(r *ciRepo, chain []string) error
+	visit = func(r *ciRepo, chain []string) error {
+		chain = append(chain, r.path)
+		switch state[r] {
+		case visiting:
+			return errors.Errorf("dependency cycle: %s", strings.Join(chain, " -> "))
+		case finished:
+			return nil
+		}
+		state[r] = visiting
+		for _, dep := range r.deps {
+			if err := visit(dep, chain); err != nil {
+				return err
+			}
+		}
+		state[r] = finished
+		return nil
+	}
+	for _, r := range repos {
+		if err := visit(r, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsRepo(repos []*ciRepo, r *ciRepo) bool {
+	for _, x := range repos {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
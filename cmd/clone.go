@@ -0,0 +1,60 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+// cloneCmd represents the clone command
+var cloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Clone the repositories of the manifest that are missing",
+	Long: `Clone clones every repository the manifest section of the config file lists
+that isn't there yet, checking out its default branch. Repositories that
+are already there are left alone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifest(cmd.Context(), cloneMissing)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cloneCmd)
+	describe(cloneCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Set up a new machine from the config file", "got clone -j 8"},
+		},
+	})
+}
+
+func cloneMissing(ctx context.Context, r workspaceRepo) error {
+
+	if isRepository(r.Path) {
+		repoSkipped(r.Path, "Skipped (already cloned)")
+		return nil
+	}
+	if err := cloneManifestRepo(ctx, r); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(r.Path, err)
+	} else {
+		repoSucceeded(r.Path, "Cloned %s", r.Remote)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	commitMessage string
+	commitAll     bool
+	commitSign    bool
+)
+
+// commitCmd represents the commit command
+var commitCmd = &cobra.Command{
+	Use:   "commit directory -m message",
+	Short: "Commit the staged changes of the repositories beneath a directory",
+	Long: `Commit runs git commit with the same message in a repository, or with -r in
+every repository beneath a directory that has staged changes, or with -a
+any changes to tracked files. Repositories with nothing to commit are
+skipped.
+
+With -S, or where commit.gpgSign is set, commits are signed. The signing
+configuration of each repository is checked first, so that a repository
+without a signing key, or whose signing program isn't installed, fails
+saying so rather than with gpg's error.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if commitMessage == "" {
+			return usageErrorf("-m is required")
+		}
+		return runOperation(ctx, args[0], commit)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(commitCmd)
+	describe(commitCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Commit the staged changes of every repository beneath ~/src", "got commit -r -m \"Update license headers\" ~/src"},
+			{"Commit all changes, signed", "got commit -r -a -S -m \"Bump dependencies\" ~/src"},
+		},
+	})
+
+	commitCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively commit subdirectories listed")
+	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Commit message")
+	commitCmd.Flags().BoolVarP(&commitAll, "all", "a", false, "Commit all changes to tracked files, not only those staged")
+	commitCmd.Flags().BoolVarP(&commitSign, "gpg-sign", "S", false, "Sign the commits")
+}
+
+func commit(ctx context.Context, path string) error {
+
+	if !isRepository(path) {
+		if recursive {
+			return nil
+		}
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	diff := []string{"diff", "--cached", "--quiet"}
+	if commitAll {
+		diff = []string{"diff", "HEAD", "--quiet"}
+	}
+	if _, err := gitOutput(ctx, path, diff...); err == nil {
+		repoSkipped(path, "Skipped (nothing to commit)")
+		return nil
+	}
+
+	if signs(ctx, path, "commit", commitSign) {
+		if err := checkSigning(ctx, path); err != nil {
+			repoFailed(path, err)
+			return nil
+		}
+	}
+
+	args := []string{"commit", "-q", "-m", commitMessage}
+	if commitAll {
+		args = append(args, "-a")
+	}
+	if commitSign {
+		args = append(args, "-S")
+	}
+
+	var out bytes.Buffer
+	if err := runner.Run(ctx, path, io.MultiWriter(&out, repoCapture(path)), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, signingError(err, out.Bytes()))
+		return nil
+	}
+
+	head, _ := gitOutput(ctx, path, "rev-parse", "--short", "HEAD")
+	repoSucceeded(path, "Committed %s", head)
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// completeDirectory completes the directory argument shared by the
+// repository commands. It offers the directories beneath the one being
+// typed, describing repositories with their current branch so they stand
+// out from plain directories, along with the names given to repositories in
+// the config file, the groups as @name and the roots known from the config
+// file: defaultPath and the root of each profile.
+func completeDirectory(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	dir, prefix := filepath.Split(toComplete)
+	list := dir
+	if list == "" {
+		list = "."
+	}
+
+	// Completion bypasses cobra's initializers, so the config file naming
+	// repositories has not been read yet.
+	initConfig()
+
+	var candidates []string
+	for root, description := range knownRoots() {
+		if strings.HasPrefix(root, toComplete) && root != toComplete {
+			candidates = append(candidates, root+"\t"+description)
+		}
+	}
+	if dir == "" {
+		for name, path := range repoNames() {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, name+"\t"+path)
+			}
+		}
+		for name, g := range groups() {
+			if strings.HasPrefix("@"+name, prefix) {
+				candidates = append(candidates, "@"+name+"\tgroup of "+strings.Join(g.Paths, ", "))
+			}
+		}
+	}
+
+	infos, err := ioutil.ReadDir(list)
+	if err != nil {
+		if len(candidates) > 0 {
+			return candidates, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() || !strings.HasPrefix(name, prefix) || name == ".git" {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+
+		path := filepath.Join(list, name)
+		description := "directory"
+		if isRepository(path) {
+			description = "repository"
+			if branch, err := git.Head(path); err == nil {
+				description = "repository on " + branch
+			}
+		}
+		candidates = append(candidates, dir+name+"/\t"+description)
+	}
+
+	return candidates, cobra.ShellCompDirectiveNoSpace
+}
+
+// knownRoots returns the directories the config file names as roots, as
+// they are written there, with a description of each.
+func knownRoots() map[string]string {
+
+	roots := map[string]string{}
+	if p := viper.GetString("defaultPath"); p != "" {
+		roots[p] = "default path"
+	}
+	for name := range viper.GetStringMap("profiles") {
+		if p := viper.GetString("profiles." + name + ".root"); p != "" {
+			roots[p] = "root of profile " + name
+		}
+	}
+	return roots
+}
+
+// completeRepositoryBranch completes the arguments of the worktree
+// commands: a repository, then one of its branches, local or on origin.
+func completeRepositoryBranch(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+
+	if len(args) == 0 {
+		return completeDirectory(cmd, args, toComplete)
+	}
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	initConfig()
+	repo := repoArg(args[0])
+	names := refNames(completionContext(cmd), repo, "refs/heads", "refs/remotes/origin")
+	return prefixed(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeCommonTag completes --tag of checkout with the tags that every
+// repository it would touch has: the one given, or with -r every repository
+// beneath it.
+func completeCommonTag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+
+	initConfig()
+	args, err := directoryArgs(args)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ctx := completionContext(cmd)
+
+	var common []string
+	repos := 0
+	add := func(path string) error {
+		tags := refNames(ctx, path, "refs/tags")
+		if repos == 0 {
+			common = tags
+		} else {
+			common = intersect(common, tags)
+		}
+		repos++
+		return nil
+	}
+	if recursive {
+		walkRepositories(ctx, args[0], add)
+	} else if isRepository(args[0]) {
+		add(args[0])
+	}
+	return prefixed(common, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionContext returns the context of cmd, which is only set when
+// completion runs through Execute.
+func completionContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// refNames returns the short names of the refs of the repository at path
+// beneath the given prefixes, such as refs/heads, without duplicates and
+// with origin/ dropped from remote branches.
+func refNames(ctx context.Context, path string, prefixes ...string) []string {
+
+	args := append([]string{"for-each-ref", "--format=%(refname)"}, prefixes...)
+	out, err := gitOutput(ctx, path, args...)
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for _, ref := range strings.Split(out, "\n") {
+		name := ref
+		for _, prefix := range prefixes {
+			name = strings.TrimPrefix(name, prefix+"/")
+		}
+		if name == "" || name == "HEAD" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// intersect returns the names in both a and b, in the order of a.
+func intersect(a, b []string) []string {
+	in := map[string]bool{}
+	for _, name := range b {
+		in[name] = true
+	}
+	var both []string
+	for _, name := range a {
+		if in[name] {
+			both = append(both, name)
+		}
+	}
+	return both
+}
+
+// prefixed returns the names that start with prefix.
+func prefixed(names []string, prefix string) []string {
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,290 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// jobs is how many repositories a recursive run works on at once, set by
+// --jobs.
+var jobs int
+
+// hostLimitTTL is how long a limit learned for a host is remembered.
+const hostLimitTTL = 24 * time.Hour
+
+// hostRetries is how many times a command a host turned away is retried.
+const hostRetries = 3
+
+// hostRetryDelay is how long the first retry of a command a host turned
+// away waits; each retry after it waits twice as long as the one before.
+const hostRetryDelay = time.Second
+
+// hostBackOffWindow is how long after lowering the limit of a host it is
+// left alone. The commands that were already running when the host turned
+// one away are likely to be turned away too, and each of them halving the
+// limit again would take it straight down to one.
+const hostBackOffWindow = 30 * time.Second
+
+// throttled matches the errors of a host that is refusing connections or
+// rate limiting, as opposed to a command that failed on its own account.
+var throttled = regexp.MustCompile(`(?i)connection reset|connection closed by|rate limit|too many requests|\b429\b|kex_exchange_identification|try again later`)
+
+// networkCommands are the git subcommands that talk to a remote.
+var networkCommands = map[string]bool{
+	"clone":     true,
+	"fetch":     true,
+	"ls-remote": true,
+	"pull":      true,
+	"push":      true,
+}
+
+// hostLimiter caps how many network commands run against each host at
+// once. A host starts at --jobs, or the lower limit learned for it on an
+// earlier run, and the limit is halved when the host turns a command away,
+// at most once every hostBackOffWindow, for the rest of the run.
+type hostLimiter struct {
+	mu      sync.Mutex
+	limits  map[string]int
+	active  map[string]int
+	lowered map[string]bool
+	halved  map[string]time.Time
+	changed chan struct{}
+}
+
+var limiter = &hostLimiter{
+	limits:  map[string]int{},
+	active:  map[string]int{},
+	lowered: map[string]bool{},
+	halved:  map[string]time.Time{},
+	changed: make(chan struct{}),
+}
+
+// limit returns the limit of host. l.mu must be held.
+func (l *hostLimiter) limit(host string) int {
+	if n, ok := l.limits[host]; ok {
+		return n
+	}
+	n := jobs
+	if learned, ok := learnedHostLimits()[host]; ok && learned.Limit < n {
+		n = learned.Limit
+	}
+	l.limits[host] = n
+	return n
+}
+
+// acquire waits until a command may run against host.
+func (l *hostLimiter) acquire(ctx context.Context, host string) error {
+	for {
+		l.mu.Lock()
+		if l.active[host] < l.limit(host) {
+			l.active[host]++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release ends a command against host.
+func (l *hostLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active[host]--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// backOff halves the limit of host, down to one, and returns the new limit.
+// A limit lowered within the last hostBackOffWindow is left as it is.
+func (l *hostLimiter) backOff(host string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.halved[host]) < hostBackOffWindow {
+		return l.limit(host)
+	}
+	l.halved[host] = time.Now()
+	n := l.limit(host) / 2
+	if n < 1 {
+		n = 1
+	}
+	l.limits[host] = n
+	l.lowered[host] = true
+	return n
+}
+
+// adaptiveRunner runs network commands within the limit of their remote's
+// host, retrying those the host turns away at a lower limit after a pause
+// that doubles with every attempt. With a single job there is nothing to
+// adapt and commands go straight to next.
+type adaptiveRunner struct {
+	next commandRunner
+}
+
+func (r adaptiveRunner) Run(ctx context.Context, path string, out io.Writer, args ...string) error {
+
+	if jobs <= 1 || len(args) == 0 || !networkCommands[args[0]] {
+		return r.next.Run(ctx, path, out, args...)
+	}
+
+	host := ""
+	if remote, err := remoteURL(ctx, path); err == nil {
+		host = remoteHost(remote)
+	}
+	if host == "" {
+		return r.next.Run(ctx, path, out, args...)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := limiter.acquire(ctx, host); err != nil {
+			return err
+		}
+		// Each attempt's output is held back so that a retried command
+		// doesn't leave the output of its failures behind.
+		var buf bytes.Buffer
+		err := r.next.Run(ctx, path, &buf, args...)
+		limiter.release(host)
+
+		if err == nil || ctx.Err() != nil || attempt > hostRetries || !throttled.Match(buf.Bytes()) {
+			if out != nil {
+				out.Write(buf.Bytes())
+			}
+			return err
+		}
+
+		n := limiter.backOff(host)
+		delay := hostRetryDelay << uint(attempt-1)
+		logger.Warn(fmt.Sprintf("[%s] %s is turning connections away; retrying in %s with at most %d at once", displayName(path), host, delay, n),
+			"path", path, "host", host, "limit", n, "delay", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			if out != nil {
+				out.Write(buf.Bytes())
+			}
+			return err
+		}
+	}
+}
+
+// remoteHost returns the host of a remote URL, or an empty string for a
+// local one.
+func remoteHost(remote string) string {
+	if host, _, ok := sshRemote(remote); ok {
+		return host
+	}
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Scheme != "file" {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// hostLimit is a limit learned for a host, as kept in the cache.
+type hostLimit struct {
+	Limit   int       `json:"limit"`
+	Learned time.Time `json:"learned"`
+}
+
+var (
+	hostLimitsOnce sync.Once
+	hostLimits     map[string]hostLimit
+)
+
+// hostLimitsFile is where learned limits are kept between runs.
+func hostLimitsFile() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "host-limits.json"), nil
+}
+
+// learnedHostLimits returns the limits learned on earlier runs that are
+// still fresh.
+func learnedHostLimits() map[string]hostLimit {
+	hostLimitsOnce.Do(func() {
+		hostLimits = map[string]hostLimit{}
+		name, err := hostLimitsFile()
+		if err != nil {
+			return
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return
+		}
+		var all map[string]hostLimit
+		if err := json.Unmarshal(data, &all); err != nil {
+			return
+		}
+		for host, l := range all {
+			if time.Since(l.Learned) < hostLimitTTL {
+				hostLimits[host] = l
+			}
+		}
+	})
+	return hostLimits
+}
+
+// saveHostLimits records the limits lowered during the run in the cache,
+// for the next run to start from.
+func saveHostLimits() {
+
+	limiter.mu.Lock()
+	lowered := map[string]int{}
+	for host := range limiter.lowered {
+		lowered[host] = limiter.limits[host]
+	}
+	limiter.mu.Unlock()
+
+	if len(lowered) == 0 {
+		return
+	}
+
+	all := learnedHostLimits()
+	for host, n := range lowered {
+		all[host] = hostLimit{Limit: n, Learned: time.Now()}
+	}
+
+	name, err := hostLimitsFile()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err == nil {
+		ioutil.WriteFile(name, append(data, '\n'), 0644)
+	}
+}
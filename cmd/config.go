@@ -0,0 +1,481 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// localConfigName is the name of a project-local config file.
+const localConfigName = ".got.yaml"
+
+var (
+	// configFiles are the config files read, besides project-local ones, in
+	// the order they were merged.
+	configFiles []string
+
+	// configErr is why a config file given with --config, or included by
+	// one, couldn't be read.
+	configErr error
+)
+
+// layerConfig merges the config file name over the config read so far,
+// after the files its configs list includes, so that it takes precedence
+// over them:
+//
+//	configs: [~/team/got.yaml]
+//
+// Included paths are relative to the file that includes them. A file
+// already merged is not merged again, which also stops include cycles.
+// announce says whether to report name itself as used.
+func layerConfig(name string, seen map[string]bool, announce bool) error {
+
+	v := viper.New()
+	v.SetConfigFile(name)
+	if err := v.ReadInConfig(); err != nil {
+		return errors.Wrapf(err, "unable to read config file [%s]", name)
+	}
+	seen[absPath(name)] = true
+
+	for _, include := range v.GetStringSlice("configs") {
+		include = expandHome(include)
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(name), include)
+		}
+		if seen[absPath(include)] {
+			continue
+		}
+		if err := layerConfig(include, seen, true); err != nil {
+			return err
+		}
+	}
+
+	if err := viper.MergeConfigMap(v.AllSettings()); err != nil {
+		return errors.Wrapf(err, "unable to read config file [%s]", name)
+	}
+	if announce {
+		fmt.Fprintln(os.Stderr, "Using config file:", name)
+		configFiles = append(configFiles, absPath(name))
+	}
+	return nil
+}
+
+// xdgConfigFile returns where the global config file lives by the XDG base
+// directory conventions: $XDG_CONFIG_HOME/got/config.yaml, by default in
+// ~/.config.
+func xdgConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = expandHome("~/.config")
+	}
+	return filepath.Join(dir, "got", "config.yaml")
+}
+
+// cacheDir returns the directory got keeps its caches in,
+// $XDG_CACHE_HOME/got or else got in the user's cache directory.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "got"), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "got"), nil
+}
+
+// dataDir returns the directory got keeps its data in by the XDG base
+// directory conventions: $XDG_DATA_HOME/got, by default in ~/.local/share.
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "got"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "got"), nil
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the config files",
+	// A broken config is what config commands are for, so they don't
+	// stop at one the way other commands do.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		commandStarted = true
+		operation = cmd.Name()
+		initStyles()
+		return usage(initLogger(cmd.Flags().Changed("log-level")))
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check the config files for unknown keys and wrong types",
+	Long: `Validate checks a config file, by default those given with --config or the
+global one, the files they include and the .got.yaml files of the current
+directory and its parents, against the keys got knows and the types of
+their values. Each problem is reported with its line, so a typo such as
+skiplst: is caught rather than silently ignored.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := args
+		if len(files) == 0 {
+			files = append(files, configFiles...)
+			if wd, err := os.Getwd(); err == nil {
+				files = append(files, localConfigs(wd)...)
+			}
+		}
+		if len(files) == 0 {
+			fmt.Println("No config files found")
+			return nil
+		}
+		return validateConfig(files)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	describe(configCmd, commandInfo{
+		Mutating: false,
+	})
+	describe(configValidateCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Check the config files in use", "got config validate"},
+			{"Check a config file before installing it", "got config validate team.yaml"},
+		},
+	})
+}
+
+// validateConfig reports the problems of each config file in files.
+func validateConfig(files []string) error {
+
+	total := 0
+	for _, name := range files {
+		problems, err := validateConfigFile(name)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s %s\n", styles.Success(iconSuccess), styles.Path(name))
+			continue
+		}
+		total += len(problems)
+		for _, p := range problems {
+			if p.line > 0 {
+				fmt.Printf("%s %s:%d:%d: %s\n", styles.Error(iconError), styles.Path(name), p.line, p.column, p.msg)
+			} else {
+				fmt.Printf("%s %s: %s\n", styles.Error(iconError), styles.Path(name), p.msg)
+			}
+		}
+	}
+
+	switch {
+	case total == 1:
+		return usageErrorf("1 problem in config files")
+	case total > 1:
+		return usageErrorf("%d problems in config files", total)
+	}
+	return nil
+}
+
+// mergeLocalConfig merges the .got.yaml files in the directory a command
+// targets and its ancestors over the global config, nearer ones taking
+// precedence, so a workspace can carry its own skip list and defaults. The
+// target is the first argument that is a directory, or else the current
+// directory. The global config file isn't merged a second time when it lies
+// on the way up. Settings that could run code or send data elsewhere are
+// ignored unless the file is trusted; see untrustedKeys.
+func mergeLocalConfig(args []string) error {
+
+	dir := ""
+	for _, arg := range args {
+		if info, err := os.Stat(repoArg(arg)); err == nil && info.IsDir() {
+			dir = repoArg(arg)
+			break
+		}
+	}
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil
+		}
+		dir = wd
+	}
+
+	var trusted []string
+	for _, t := range viper.GetStringSlice("trustedLocalConfigs") {
+		trusted = append(trusted, absPath(expandHome(t)))
+	}
+
+	for _, name := range localConfigs(dir) {
+		v := viper.New()
+		v.SetConfigFile(name)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return errors.Wrapf(err, "unable to read config file [%s]", name)
+		}
+		settings := v.AllSettings()
+		if !within(filepath.Dir(name), trusted) {
+			if dropped := dropUntrusted(settings); len(dropped) > 0 {
+				fmt.Fprintf(os.Stderr, "Ignoring %s in %s: list its directory under trustedLocalConfigs in your own config file to allow them\n",
+					strings.Join(dropped, ", "), name)
+			}
+		}
+		if err := viper.MergeConfigMap(settings); err != nil {
+			return errors.Wrapf(err, "unable to read config file [%s]", name)
+		}
+		fmt.Fprintln(os.Stderr, "Using config file:", name)
+	}
+	return nil
+}
+
+// untrustedKeys are the settings a project-local config file only gets to
+// make when its directory is listed under trustedLocalConfigs in one of the
+// user's own config files, as they run commands, send data off the machine
+// or write files elsewhere. A clone of someone else's repository would
+// otherwise run its code on a got status, with hooks or with gitPath and
+// gitGlobalArgs, where -c core.fsmonitor=... is as good as a hook.
+//
+//	trustedLocalConfigs: [~/src/work]
+//
+// Keys are lower case, as viper reads them.
+var untrustedKeys = map[string]bool{
+	"hooks":               true,
+	"ci":                  true,
+	"command":             true,
+	"maintenance":         true,
+	"webhook":             true,
+	"forges":              true,
+	"gitpath":             true,
+	"gitglobalargs":       true,
+	"ssh":                 true,
+	"configs":             true,
+	"trustedlocalconfigs": true,
+	"logfile":             true,
+	"metricsfile":         true,
+	"outputfile":          true,
+	"reportfile":          true,
+	"cpuprofile":          true,
+	"memprofile":          true,
+	"trace":               true,
+}
+
+// dropUntrusted removes the untrustedKeys, and the hooks of groups, from the
+// settings of a project-local config file, and from each of its profiles,
+// and returns those it removed.
+func dropUntrusted(settings map[string]interface{}) []string {
+
+	found := map[string]bool{}
+	drop := func(m map[string]interface{}, prefix string) {
+		for key := range m {
+			if untrustedKeys[key] {
+				delete(m, key)
+				found[prefix+key] = true
+			}
+		}
+		// Groups can have hooks of their own.
+		groups, _ := m["groups"].(map[string]interface{})
+		for name, g := range groups {
+			if g, ok := g.(map[string]interface{}); ok {
+				if _, ok := g["hooks"]; ok {
+					delete(g, "hooks")
+					found[prefix+"groups."+name+".hooks"] = true
+				}
+			}
+		}
+	}
+	drop(settings, "")
+	if profiles, ok := settings["profiles"].(map[string]interface{}); ok {
+		for name, p := range profiles {
+			if m, ok := p.(map[string]interface{}); ok {
+				drop(m, "profiles."+name+".")
+			}
+		}
+	}
+
+	dropped := make([]string, 0, len(found))
+	for key := range found {
+		dropped = append(dropped, key)
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// localConfigs returns the .got.yaml files in dir and its ancestors,
+// farthest first, leaving out the config files already read.
+func localConfigs(dir string) []string {
+
+	read := map[string]bool{}
+	for _, name := range configFiles {
+		read[name] = true
+	}
+
+	var found []string
+	for dir = absPath(dir); ; dir = filepath.Dir(dir) {
+		name := filepath.Join(dir, localConfigName)
+		if info, err := os.Stat(name); err == nil && !info.IsDir() && !read[name] {
+			found = append([]string{name}, found...)
+		}
+		if filepath.Dir(dir) == dir {
+			return found
+		}
+	}
+}
+
+// profileRoot is the directory commands work on when none is given, from
+// the root of the selected profile.
+var profileRoot string
+
+// applyProfile merges the profile selected by --profile, or the profile key
+// of the config, over the config:
+//
+//	profiles:
+//	  work:
+//	    root: ~/src/work
+//	    skip: [legacy-*]
+//	    jobs: 8
+//	  oss:
+//	    root: ~/src/oss
+//	    maxDepth: 2
+//
+// A profile can set anything the config file can. Keys named after a flag
+// of cmd, such as jobs, maxDepth or failFast, set that flag unless it is
+// given on the command line, and root is the directory to work on when no
+// directory argument is given.
+func applyProfile(cmd *cobra.Command) error {
+
+	name := viper.GetString("profile")
+	if name == "" {
+		return nil
+	}
+
+	profiles := viper.GetStringMap("profiles")
+	p, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return usageErrorf("unknown profile [%s], none are configured", name)
+		}
+		return usageErrorf("unknown profile [%s], expected one of %s", name, strings.Join(names, ", "))
+	}
+	settings, ok := p.(map[string]interface{})
+	if !ok {
+		return usageErrorf("profile [%s] is not a set of settings", name)
+	}
+
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return errors.Wrapf(err, "unable to apply profile [%s]", name)
+	}
+	if root, ok := settings["root"].(string); ok {
+		profileRoot = expandHome(root)
+	}
+	return errors.Wrapf(configFlags(cmd, settings), "invalid profile [%s]", name)
+}
+
+// tuningKeys are the config keys that set the flags of the same name for
+// every run, unless given on the command line.
+var tuningKeys = []string{"maxDepth", "jobs", "timeout", "totalTimeout"}
+
+// applyTuning sets the flags named by tuningKeys from the config, where the
+// selected profile has already been merged.
+//
+//	maxDepth: 4
+//	jobs: 8
+//	timeout: 2m
+//	totalTimeout: 30m
+func applyTuning(cmd *cobra.Command) error {
+	settings := map[string]interface{}{}
+	for _, key := range tuningKeys {
+		if viper.IsSet(key) {
+			settings[key] = viper.Get(key)
+		}
+	}
+	return errors.Wrap(configFlags(cmd, settings), "invalid config")
+}
+
+// configFlags sets the flags of cmd named by the keys of settings, written
+// in camel case as in maxDepth for --max-depth, that aren't given on the
+// command line. Keys that don't name a flag are left alone.
+func configFlags(cmd *cobra.Command, settings map[string]interface{}) error {
+
+	// Config keys are case-insensitive, so flags are matched by their name
+	// without dashes, lower cased.
+	flags := map[string]*pflag.Flag{}
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		flags[strings.ToLower(strings.ReplaceAll(f.Name, "-", ""))] = f
+	})
+
+	for key, v := range settings {
+		f, ok := flags[strings.ToLower(key)]
+		if !ok || f.Changed || f.Name == "config" || f.Name == "profile" {
+			continue
+		}
+		value := fmt.Sprint(v)
+		if list, ok := v.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(items, ",")
+		}
+		if err := f.Value.Set(value); err != nil {
+			return errors.Wrapf(err, "invalid %s [%s]", key, value)
+		}
+	}
+	return nil
+}
+
+// directoryArgs returns args with the directory a command works on first,
+// resolved with repoArg. With no arguments that is the root of the selected
+// profile, or else the defaultPath of the config file. A group given as
+// @name is kept as it is, for walkRepositories, and makes the run recursive.
+func directoryArgs(args []string) ([]string, error) {
+	if len(args) < 1 {
+		root := profileRoot
+		if root == "" {
+			root = expandHome(viper.GetString("defaultPath"))
+		}
+		if root == "" {
+			return nil, usageErrorf("directory argument is required")
+		}
+		return directoryArgs([]string{root})
+	}
+	if _, _, ok, err := groupArg(args[0]); err != nil {
+		return nil, err
+	} else if ok {
+		recursive = true
+		return args, nil
+	}
+	args[0] = repoArg(args[0])
+	return args, nil
+}
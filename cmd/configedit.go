@@ -0,0 +1,209 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// configEntry is an entry of a config file for a repository: its short
+// name under names, or one of the paths of a group under groups. got remove
+// takes them out of the config files, so that they don't go on naming a
+// repository that isn't there, and got remove --undo puts them back.
+type configEntry struct {
+	File  string `json:"file"`
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// forgetRepository takes the entries for the repository at path out of the
+// config files read, and returns them. Only a group path naming the
+// repository itself is taken out; a glob that matches it no longer will.
+func forgetRepository(path string) ([]configEntry, error) {
+
+	var forgotten []configEntry
+	for _, file := range configFiles {
+		var found []configEntry
+		err := editConfig(file, func(root *yaml.Node) bool {
+			if names := mappingValue(root, "names"); names != nil && names.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(names.Content); {
+					name, value := names.Content[i], names.Content[i+1]
+					if samePath(absPath(expandHome(value.Value)), path) {
+						found = append(found, configEntry{File: file, Key: "names", Name: name.Value, Value: value.Value})
+						names.Content = append(names.Content[:i], names.Content[i+2:]...)
+						continue
+					}
+					i += 2
+				}
+			}
+			if groups := mappingValue(root, "groups"); groups != nil && groups.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(groups.Content); i += 2 {
+					paths := groupPathsNode(groups.Content[i+1], false)
+					if paths == nil {
+						continue
+					}
+					for j := 0; j < len(paths.Content); {
+						if p := paths.Content[j]; samePath(absPath(expandHome(p.Value)), path) {
+							found = append(found, configEntry{File: file, Key: "groups", Name: groups.Content[i].Value, Value: p.Value})
+							paths.Content = append(paths.Content[:j], paths.Content[j+1:]...)
+							continue
+						}
+						j++
+					}
+				}
+			}
+			return len(found) > 0
+		})
+		if err != nil {
+			return forgotten, err
+		}
+		forgotten = append(forgotten, found...)
+	}
+	return forgotten, nil
+}
+
+// rememberRepository puts entries taken out by forgetRepository back into
+// their config files. A name that has been given to another repository in
+// the meantime is left alone, and a group that is gone is given again as a
+// list of paths.
+func rememberRepository(entries []configEntry) error {
+
+	byFile := map[string][]configEntry{}
+	var files []string
+	for _, e := range entries {
+		if _, ok := byFile[e.File]; !ok {
+			files = append(files, e.File)
+		}
+		byFile[e.File] = append(byFile[e.File], e)
+	}
+
+	for _, file := range files {
+		err := editConfig(file, func(root *yaml.Node) bool {
+			changed := false
+			for _, e := range byFile[file] {
+				section := mappingValue(root, e.Key)
+				if section == nil {
+					section = &yaml.Node{Kind: yaml.MappingNode}
+					root.Content = append(root.Content, scalarNode(e.Key), section)
+				}
+				if section.Kind != yaml.MappingNode {
+					continue
+				}
+				existing := mappingValue(section, e.Name)
+				switch {
+				case e.Key == "names" && existing == nil:
+					section.Content = append(section.Content, scalarNode(e.Name), scalarNode(e.Value))
+					changed = true
+				case e.Key == "groups" && existing == nil:
+					section.Content = append(section.Content, scalarNode(e.Name),
+						&yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, Content: []*yaml.Node{scalarNode(e.Value)}})
+					changed = true
+				case e.Key == "groups":
+					paths := groupPathsNode(existing, true)
+					if paths == nil {
+						continue
+					}
+					listed := false
+					for _, p := range paths.Content {
+						listed = listed || p.Value == e.Value
+					}
+					if !listed {
+						paths.Content = append(paths.Content, scalarNode(e.Value))
+						changed = true
+					}
+				}
+			}
+			return changed
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editConfig reads the config file name, lets edit change its top-level
+// mapping, and writes it back if edit reports a change. Comments are kept,
+// though the file is laid out afresh.
+func editConfig(name string, edit func(root *yaml.Node) bool) error {
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read config file [%s]", name)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return errors.Wrapf(err, "unable to read config file [%s]", name)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode || !edit(doc.Content[0]) {
+		return nil
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return errors.Wrapf(err, "unable to write config file [%s]", name)
+	}
+	enc.Close()
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return errors.Wrapf(ioutil.WriteFile(name, b.Bytes(), mode), "unable to write config file [%s]", name)
+}
+
+// mappingValue returns the value of key in the mapping m, matched without
+// regard to case as viper reads keys, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// groupPathsNode returns the list of paths of a group in the config file:
+// the group itself when it is given as a list, or else its paths, which are
+// added when create is set and it has none.
+func groupPathsNode(g *yaml.Node, create bool) *yaml.Node {
+	switch g.Kind {
+	case yaml.SequenceNode:
+		return g
+	case yaml.MappingNode:
+		paths := mappingValue(g, "paths")
+		if paths == nil && create {
+			paths = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			g.Content = append(g.Content, scalarNode("paths"), paths)
+		}
+		if paths != nil && paths.Kind == yaml.SequenceNode {
+			return paths
+		}
+	}
+	return nil
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,201 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configInitLocal    bool
+	configInitForce    bool
+	configInitDefaults bool
+)
+
+var configInitCmd = &cobra.Command{
+	Use:   "init [file]",
+	Short: "Write a starter config file",
+	Long: `Init asks a few questions about where your repositories live and writes a
+commented config file from the answers: a default profile rooted there, a
+group for each of the directories named and a starter skip list.
+
+The file is $XDG_CONFIG_HOME/got/config.yaml, by default in ~/.config,
+unless one is given, or with --local .got.yaml in the current directory.
+An existing file is only replaced with --force. --defaults takes the
+suggested answers without asking.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := xdgConfigFile()
+		switch {
+		case len(args) > 0:
+			name = args[0]
+		case configInitLocal:
+			name = localConfigName
+		}
+		if _, err := os.Stat(name); err == nil && !configInitForce {
+			return errors.Errorf("[%s] already exists, use --force to replace it", name)
+		}
+		return configInit(name)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configInitCmd)
+	describe(configInitCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Write ~/.config/got/config.yaml", "got config init"},
+			{"Write a .got.yaml for the workspace in the current directory", "got config init --local"},
+		},
+	})
+
+	configInitCmd.Flags().BoolVar(&configInitLocal, "local", false, "Write .got.yaml in the current directory")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "Replace an existing config file")
+	configInitCmd.Flags().BoolVar(&configInitDefaults, "defaults", false, "Take the suggested answers without asking")
+}
+
+// starterConfig is what config init writes a config file from.
+type starterConfig struct {
+	Root   string
+	Groups []starterGroup
+	Jobs   int
+	Hidden bool
+}
+
+type starterGroup struct {
+	Name, Path string
+}
+
+var starterTemplate = template.Must(template.New("config").Parse(`# got config file, written by got config init.
+# Check it with got config validate after editing.
+
+# Repositories to leave alone, by directory name or, with a /, by path.
+skip:
+  - "*.bak"
+  - "*.orig"
+  - archive
+
+# Descend into hidden directories in recursive runs.
+hidden: {{.Hidden}}
+
+# The default profile is used unless --profile names another. Its root is
+# the directory to work on when none is given, so got pull -r needs no
+# argument, and any flag can be set in camel case, as in maxDepth.
+profile: default
+profiles:
+  default:
+    root: {{printf "%q" .Root}}
+    jobs: {{.Jobs}}
+    # maxDepth: 3
+    # failFast: true
+{{if .Groups}}
+# Groups share settings for the repositories beneath their paths.
+groups:
+{{- range .Groups}}
+  {{.Name}}:
+    paths: [{{printf "%q" .Path}}]
+{{- end}}
+{{else}}
+# Groups share settings for the repositories beneath their paths:
+#
+# groups:
+#   work:
+#     paths: [~/src/work]
+#     failFast: true
+#   oss:
+#     paths: [~/src/oss]
+#     skip: [legacy-*]
+{{end}}
+# Short names for repositories, usable wherever a directory is:
+#
+# names:
+#   api: ~/src/work/api
+`))
+
+// configInit asks about the workspace and writes the config file name.
+func configInit(name string) error {
+
+	ask := func(question, suggested string) string {
+		if configInitDefaults {
+			return suggested
+		}
+		if answer := prompt(fmt.Sprintf("%s [%s] ", question, suggested)); answer != "" {
+			return answer
+		}
+		return suggested
+	}
+
+	c := starterConfig{Jobs: 4}
+
+	c.Root = ask("Where do you keep your repositories?", "~/src")
+	if info, err := os.Stat(expandHome(c.Root)); err != nil || !info.IsDir() {
+		logger.Warn(fmt.Sprintf("[%s] doesn't exist yet", c.Root), "path", c.Root)
+	}
+
+	groups := ask("Directories in it to make groups of, separated by spaces (none for no groups)", strings.Join(subdirectories(expandHome(c.Root)), " "))
+	for _, g := range strings.Fields(groups) {
+		if g != "none" {
+			c.Groups = append(c.Groups, starterGroup{Name: g, Path: strings.TrimSuffix(c.Root, "/") + "/" + g})
+		}
+	}
+
+	jobs := ask("How many repositories to work on at once?", strconv.Itoa(c.Jobs))
+	n, err := strconv.Atoi(jobs)
+	if err != nil || n < 1 {
+		return errors.Errorf("invalid number of repositories at once [%s]", jobs)
+	}
+	c.Jobs = n
+
+	c.Hidden = strings.HasPrefix(strings.ToLower(ask("Look in hidden directories?", "n")), "y")
+
+	var b strings.Builder
+	if err := starterTemplate.Execute(&b, c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return errors.Wrapf(err, "unable to write config file [%s]", name)
+	}
+	if err := ioutil.WriteFile(name, []byte(b.String()), 0644); err != nil {
+		return errors.Wrapf(err, "unable to write config file [%s]", name)
+	}
+	fmt.Printf("%s Wrote %s\n", styles.Success(iconSuccess), styles.Path(name))
+	return nil
+}
+
+// subdirectories returns the names of the directories in dir that aren't
+// repositories themselves, as candidates for groups.
+func subdirectories(dir string) []string {
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || isRepository(filepath.Join(dir, e.Name())) {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names
+}
@@ -0,0 +1,303 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeList        bool
+	dedupeSuggest     bool
+	dedupeToWorktrees bool
+	dedupeDryRun      bool
+)
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe directory...",
+	Short: "Find multiple clones of the same remote",
+	Long: `Dedupe scans the given directories for repositories whose origin points at
+the same remote URL and reports each set of duplicates with its size on disk
+and when it was last active.
+
+With --suggest each duplicate set is annotated with which clone to keep and
+whether the others can simply be removed or should become worktrees of the
+clone that is kept.
+
+With --to-worktrees every clone but the most recently active one is replaced
+by a linked worktree of that primary clone, on the same branch. The old
+checkout is moved to the got trash and can be restored with got remove --undo.
+Clones with uncommitted changes, or with local commits on branches other than
+the one checked out, are left alone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		return dedupe(ctx, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dedupeCmd)
+	describe(dedupeCmd, commandInfo{
+		Mutating:    true,
+		Destructive: true,
+		Examples: []example{
+			{"Report duplicate clones with a suggestion for each", "got dedupe --suggest ~/src ~/work"},
+			{"Show which clones would become worktrees", "got dedupe --to-worktrees --dry-run ~/src"},
+		},
+	})
+
+	dedupeCmd.Flags().BoolVar(&dedupeList, "list", true, "List duplicate clones")
+	dedupeCmd.Flags().BoolVar(&dedupeSuggest, "suggest", false, "Suggest which clone to keep and what to do with the others")
+	dedupeCmd.Flags().BoolVar(&dedupeToWorktrees, "to-worktrees", false, "Convert secondary clones into worktrees of the primary clone")
+	dedupeCmd.Flags().BoolVarP(&dedupeDryRun, "dry-run", "n", false, "With --to-worktrees, show what would be converted without changing anything")
+}
+
+// clone describes one checkout of a remote found by dedupe.
+type clone struct {
+	path     string
+	size     int64
+	activity time.Time
+}
+
+func dedupe(ctx context.Context, roots []string) error {
+
+	sets, err := findDuplicates(ctx, roots)
+	if err != nil {
+		return err
+	}
+
+	if len(sets) == 0 {
+		fmt.Println("No duplicate clones found")
+		return nil
+	}
+
+	urls := make([]string, 0, len(sets))
+	for url := range sets {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	if dedupeToWorktrees {
+		for _, url := range urls {
+			primary := sets[url][0]
+			for _, secondary := range sets[url][1:] {
+				if err := convertToWorktree(ctx, primary.path, secondary.path); err != nil {
+					repoSkipped(secondary.path, "%v", err)
+				}
+			}
+		}
+		return nil
+	}
+
+	if !dedupeList {
+		return nil
+	}
+
+	for _, url := range urls {
+		clones := sets[url]
+		fmt.Printf("%s (%d clones)\n", url, len(clones))
+		for i, c := range clones {
+			active := "never"
+			if !c.activity.IsZero() {
+				active = c.activity.Format("2006-01-02")
+			}
+			fmt.Printf("  %-60s %10s  last active %-10s", c.path, formatBytes(c.size), active)
+			if dedupeSuggest {
+				fmt.Printf("  %s", suggestion(ctx, i, c))
+			}
+			fmt.Println()
+		}
+	}
+
+	return nil
+}
+
+// findDuplicates returns, keyed by normalized remote URL, every remote that
+// has more than one clone beneath roots. Clones are ordered most recently
+// active first. A linked worktree shares its repository with the clone it
+// belongs to rather than duplicating it, so worktrees are left out, as is
+// a clone reached a second time, through a symbolic link for instance.
+func findDuplicates(ctx context.Context, roots []string) (map[string][]clone, error) {
+
+	byURL := map[string][]clone{}
+	seen := map[string]bool{}
+
+	for _, root := range roots {
+		err := walkRepositories(ctx, root, func(path string) error {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil
+			}
+			common, linked, err := commonDir(ctx, abs)
+			if err != nil || linked || seen[common] {
+				return nil
+			}
+			seen[common] = true
+
+			url, err := remoteURL(ctx, abs)
+			if err != nil || url == "" {
+				return nil
+			}
+			key := normalizeRemoteURL(url)
+			byURL[key] = append(byURL[key], clone{path: abs, size: dirSize(abs), activity: lastActivity(ctx, abs)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for url, clones := range byURL {
+		if len(clones) < 2 {
+			delete(byURL, url)
+			continue
+		}
+		sort.Slice(clones, func(i, j int) bool { return clones[i].activity.After(clones[j].activity) })
+	}
+
+	return byURL, nil
+}
+
+// convertToWorktree replaces the clone at secondary with a worktree of
+// primary that has the same branch checked out.
+func convertToWorktree(ctx context.Context, primary, secondary string) error {
+
+	if changes, err := gitOutput(ctx, secondary, "status", "--porcelain"); err != nil {
+		return errors.Wrap(err, "unable to read status")
+	} else if changes != "" {
+		return errors.New("has uncommitted changes")
+	}
+
+	branch, err := gitOutput(ctx, secondary, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return errors.New("HEAD is detached")
+	}
+	if err := guardProtected(branch); err != nil {
+		return err
+	}
+
+	// Commits on other local branches only exist in this clone and would be
+	// lost; only the checked out branch is carried over to the primary.
+	stranded, err := gitOutput(ctx, secondary, "rev-list", "--branches", "--not", "--remotes", "refs/heads/"+branch)
+	if err != nil {
+		return errors.Wrap(err, "unable to list unpushed commits")
+	} else if stranded != "" {
+		return errors.New("has unpushed commits on branches other than " + branch)
+	}
+
+	if current, _ := gitOutput(ctx, primary, "symbolic-ref", "--short", "HEAD"); current == branch {
+		return errors.Errorf("branch %s is already checked out in [%s]", branch, primary)
+	}
+
+	if dedupeDryRun {
+		repoSucceeded(secondary, "Would become a worktree of [%s] on %s", primary, branch)
+		return nil
+	}
+
+	// Bring the branch over first; this refuses to rewind a branch the
+	// primary already has.
+	spec := fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)
+	if out, err := gitCommand(ctx, primary, "fetch", secondary, spec).CombinedOutput(); err != nil {
+		return errors.Errorf("unable to fetch %s into [%s]: %s", branch, primary, strings.TrimSpace(string(out)))
+	}
+
+	entry, err := moveToTrash(ctx, secondary)
+	if err != nil {
+		return err
+	}
+
+	if out, err := gitCommand(ctx, primary, "worktree", "add", secondary, branch).CombinedOutput(); err != nil {
+		os.RemoveAll(secondary)
+		if rerr := moveDir(filepath.Join(entry, "repo"), secondary); rerr == nil {
+			os.RemoveAll(entry)
+		}
+		return errors.Errorf("unable to add worktree: %s", strings.TrimSpace(string(out)))
+	}
+
+	repoSucceeded(secondary, "Converted to a worktree of [%s] on %s", primary, branch)
+	return nil
+}
+
+// suggestion describes what to do with the i'th most recently active clone
+// of a duplicate set.
+func suggestion(ctx context.Context, i int, c clone) string {
+	if i == 0 {
+		return "keep"
+	}
+	if err := checkRemovable(ctx, c.path); err != nil {
+		return "convert to worktree (has local work)"
+	}
+	return "remove"
+}
+
+// lastActivity returns the later of the last commit time and the last time
+// the index was written, which catches checkouts and staging as well as
+// commits.
+func lastActivity(ctx context.Context, path string) time.Time {
+
+	var last time.Time
+
+	if out, err := gitOutput(ctx, path, "log", "-1", "--format=%ct"); err == nil {
+		if secs, err := strconv.ParseInt(out, 10, 64); err == nil {
+			last = time.Unix(secs, 0)
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(gitDir(path), "index")); err == nil && info.ModTime().After(last) {
+		last = info.ModTime()
+	}
+
+	return last
+}
+
+// dirSize returns the total size of the regular files beneath path.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,86 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the workspace against the manifest in the config file",
+	Long: `Doctor checks every repository the manifest section of the config file
+lists: that it is there, that its origin remote is the one listed and that
+it has the listed default branch. Sync fixes what doctor finds.
+
+It first checks the forge tokens in GITHUB_TOKEN (or GH_TOKEN) and
+GITLAB_TOKEN, for github.com, gitlab.com and the forges in the config file:
+that they haven't expired, and that they have the scopes got prs needs,
+repo on GitHub and read_api on GitLab. Tokens expiring within a week are
+warned about.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		problems := checkTokens(ctx)
+		if !viper.IsSet("manifest") && len(tokenForges()) > 0 {
+			// Only the tokens to check.
+			return tokenProblems(problems)
+		}
+		err := runManifest(ctx, doctor)
+		if err == nil {
+			err = tokenProblems(problems)
+		}
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+	describe(doctorCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Check the workspace", "got doctor"},
+		},
+	})
+}
+
+// tokenProblems returns an error counting the forge tokens with problems,
+// if there are any.
+func tokenProblems(n int) error {
+	switch {
+	case n == 1:
+		return errors.New("1 forge token needs attention")
+	case n > 1:
+		return errors.Errorf("%d forge tokens need attention", n)
+	}
+	return nil
+}
+
+func doctor(ctx context.Context, r workspaceRepo) error {
+
+	if !isRepository(r.Path) {
+		repoFailed(r.Path, errors.New("missing"))
+	} else if problems := manifestDrift(ctx, r); len(problems) > 0 {
+		repoFailed(r.Path, errors.New(strings.Join(problems, "; ")))
+	} else {
+		repoSucceeded(r.Path, "Matches manifest")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Exit codes, for scripts.
+const (
+	exitOK          = 0
+	exitFailures    = 1
+	exitUsage       = 2
+	exitInterrupted = 130
+)
+
+// maxFailures is how many repositories may fail, set by --max-failures,
+// before got exits with exitFailures.
+var maxFailures int
+
+// failuresError reports that an operation failed in some repositories, as
+// opposed to got being unable to do what it was asked.
+type failuresError struct {
+	msg string
+}
+
+func (e failuresError) Error() string { return e.msg }
+
+// repositoriesFailed returns a failuresError.
+func repositoriesFailed(format string, args ...interface{}) error {
+	return failuresError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitStatusError reports that a program got handed over to, such as
+// got-ui, exited with code, having reported why itself.
+type exitStatusError struct {
+	code int
+}
+
+func (e exitStatusError) Error() string { return fmt.Sprintf("exit status %d", e.code) }
+
+// usageError reports that got was asked for something it can't do as
+// asked: a command, flag or argument it doesn't take, a flag value or
+// config setting that isn't valid, or one that is missing.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+// usageErrorf returns a usageError.
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{err: errors.Errorf(format, args...)}
+}
+
+// usage marks err, if there is one, as a usageError.
+func usage(err error) error {
+	if err == nil {
+		return nil
+	}
+	return usageError{err: err}
+}
+
+// commandStarted is set once cobra has parsed the command line and the
+// command starts to run. Errors cobra returns before then are about the
+// command line: an unknown command or flag, or the wrong arguments.
+var commandStarted bool
+
+// exitCode decides how got exits after a run that returned err: a usage
+// error exits with exitUsage, and any other error, as repositories failing,
+// with exitFailures.
+func exitCode(interrupted bool, err error) int {
+
+	if interrupted {
+		return exitInterrupted
+	}
+	switch e := errors.Cause(err).(type) {
+	case nil:
+	case exitStatusError:
+		return e.code
+	case usageError:
+		return exitUsage
+	case failuresError:
+	default:
+		if !commandStarted {
+			return exitUsage
+		}
+		return exitFailures
+	}
+	if failureCount() > maxFailures {
+		return exitFailures
+	}
+	return exitOK
+}
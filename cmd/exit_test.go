@@ -0,0 +1,62 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestExitCode(t *testing.T) {
+	defer func(started bool, max int) { commandStarted, maxFailures = started, max }(commandStarted, maxFailures)
+	defer atomic.StoreInt32(&failures, atomic.LoadInt32(&failures))
+
+	tests := []struct {
+		name        string
+		started     bool
+		interrupted bool
+		failed      int32
+		err         error
+		want        int
+	}{
+		{"success", true, false, 0, nil, exitOK},
+		{"failures allowed", true, false, 1, nil, exitOK},
+		{"too many failures", true, false, 2, repositoriesFailed("2 repositories failed"), exitFailures},
+		{"runtime error", true, false, 0, errors.New("unable to lock"), exitFailures},
+		{"wrapped runtime error", true, false, 0, errors.Wrap(errors.New("connection refused"), "fetch"), exitFailures},
+		{"usage error", true, false, 0, usageErrorf("--interval needs a duration"), exitUsage},
+		{"wrapped usage error", true, false, 0, errors.Wrap(usage(errors.New("unknown key")), "config"), exitUsage},
+		{"command line error", false, false, 0, errors.New(`unknown command "nosuch" for "got"`), exitUsage},
+		{"handed over", true, false, 0, exitStatusError{code: 3}, 3},
+		{"interrupted", true, true, 2, errors.New("context canceled"), exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandStarted, maxFailures = tt.started, 1
+			atomic.StoreInt32(&failures, tt.failed)
+			if got := exitCode(tt.interrupted, tt.err); got != tt.want {
+				t.Errorf("exitCode(%v, %v) = %d, want %d", tt.interrupted, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageNil(t *testing.T) {
+	if err := usage(nil); err != nil {
+		t.Errorf("usage(nil) = %v, want nil", err)
+	}
+}
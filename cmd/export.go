@@ -0,0 +1,248 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export directory --format yaml|json|ghq|mrconfig|repo-manifest",
+	Short: "Write the repositories beneath a directory in another tool's format",
+	Long: `Export finds every repository beneath a directory and writes it to stdout,
+with its origin remote and current branch, as a got manifest or in the
+format of another multi-repository tool:
+
+  yaml           the manifest section of a got config file, for got clone to
+                 recreate the workspace elsewhere
+  json           the same manifest as JSON
+  ghq            one remote URL per line, for ghq import
+  mrconfig       an .mrconfig for myrepos, with paths relative to the directory
+  repo-manifest  a manifest for Android's repo tool
+
+Manifest paths are absolute, with the home directory written as ~ so that
+they carry over to another account. A detached HEAD is exported without a
+branch. Repositories without an origin remote can't be recreated elsewhere
+and are left out with a warning.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		write, ok := exporters[exportFormat]
+		if !ok {
+			return usageErrorf("unknown export format [%s], expected yaml, json, ghq, mrconfig or repo-manifest", exportFormat)
+		}
+		repos, err := exportedRepositories(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return write(os.Stdout, repos)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	describe(exportCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Write a manifest of ~/src for got clone to recreate it elsewhere", "got export --format yaml ~/src > workspace.yaml"},
+			{"Hand the repositories beneath ~/src to ghq", "got export --format ghq ~/src | ghq import"},
+			{"Write an .mrconfig for a teammate using myrepos", "got export --format mrconfig ~/src > ~/src/.mrconfig"},
+		},
+	})
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "", "Format to write: yaml, json, ghq, mrconfig or repo-manifest")
+}
+
+// exportedRepository is a repository as the export formats see it.
+type exportedRepository struct {
+	path     string // relative to the exported directory
+	abs      string
+	url      string
+	branch   string
+	detached bool
+}
+
+var exporters = map[string]func(w io.Writer, repos []exportedRepository) error{
+	"yaml":          exportYAML,
+	"json":          exportJSON,
+	"ghq":           exportGhq,
+	"mrconfig":      exportMrconfig,
+	"repo-manifest": exportRepoManifest,
+}
+
+// exportedRepositories returns the repositories beneath root that have an
+// origin remote, sorted by path.
+func exportedRepositories(ctx context.Context, root string) ([]exportedRepository, error) {
+
+	var repos []exportedRepository
+	err := walkRepositories(ctx, root, func(path string) error {
+		url, err := remoteURL(ctx, path)
+		if err != nil || url == "" {
+			logger.Warn(fmt.Sprintf("%s [%s]:  %s", styles.Muted(iconSkipped), styles.Path(displayName(path)), styles.Muted("Skipped (no origin remote)")), "path", path)
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		branch, _ := git.Head(path)
+		_, err = gitOutput(ctx, path, "symbolic-ref", "--quiet", "HEAD")
+		repos = append(repos, exportedRepository{path: filepath.ToSlash(rel), abs: absPath(path), url: url, branch: branch, detached: err != nil})
+		return nil
+	})
+
+	sort.Slice(repos, func(i, j int) bool { return repos[i].path < repos[j].path })
+	return repos, err
+}
+
+// exportedManifest returns repos as the repositories of a got manifest.
+func exportedManifest(repos []exportedRepository) []workspaceRepo {
+	manifest := make([]workspaceRepo, len(repos))
+	for i, r := range repos {
+		manifest[i] = workspaceRepo{Path: abbreviateHome(r.abs), Remote: r.url}
+		if !r.detached {
+			manifest[i].Branch = r.branch
+		}
+	}
+	return manifest
+}
+
+func exportYAML(w io.Writer, repos []exportedRepository) error {
+	return writeConfigManifest(w, "Exported by got export", exportedManifest(repos), nil)
+}
+
+func exportJSON(w io.Writer, repos []exportedRepository) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Manifest []workspaceRepo `json:"manifest"`
+	}{exportedManifest(repos)})
+}
+
+func exportGhq(w io.Writer, repos []exportedRepository) error {
+	for _, r := range repos {
+		fmt.Fprintln(w, r.url)
+	}
+	return nil
+}
+
+func exportMrconfig(w io.Writer, repos []exportedRepository) error {
+	for i, r := range repos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "[%s]\ncheckout = git clone %s %s\n", r.path, shellQuote(r.url), shellQuote(filepath.Base(r.path)))
+	}
+	return nil
+}
+
+// manifest is a manifest of Android's repo tool, as export writes it and
+// import reads it.
+type manifest struct {
+	XMLName        xml.Name          `xml:"manifest"`
+	Remotes        []manifestRemote  `xml:"remote"`
+	Default        *manifestDefault  `xml:"default"`
+	Projects       []manifestProject `xml:"project"`
+	RemoveProjects []manifestProject `xml:"remove-project"`
+	Includes       []manifestInclude `xml:"include"`
+}
+
+type manifestRemote struct {
+	Name     string `xml:"name,attr"`
+	Fetch    string `xml:"fetch,attr"`
+	Revision string `xml:"revision,attr,omitempty"`
+}
+
+type manifestDefault struct {
+	Remote   string `xml:"remote,attr,omitempty"`
+	Revision string `xml:"revision,attr,omitempty"`
+}
+
+type manifestProject struct {
+	Name     string `xml:"name,attr"`
+	Path     string `xml:"path,attr,omitempty"`
+	Remote   string `xml:"remote,attr,omitempty"`
+	Revision string `xml:"revision,attr,omitempty"`
+	Groups   string `xml:"groups,attr,omitempty"`
+}
+
+type manifestInclude struct {
+	Name string `xml:"name,attr"`
+}
+
+// exportRepoManifest writes a repo manifest with a remote for every
+// distinct URL prefix, as repo joins a remote's fetch URL and a project's
+// name to clone it.
+func exportRepoManifest(w io.Writer, repos []exportedRepository) error {
+
+	var m manifest
+	remotes := map[string]string{}
+	for _, r := range repos {
+		i := strings.LastIndexAny(r.url, "/:")
+		if i < 0 {
+			return errors.Errorf("unable to split remote URL [%s] of [%s]", r.url, r.path)
+		}
+		fetch, name := r.url[:i], strings.TrimSuffix(r.url[i+1:], ".git")
+		if r.url[i] == ':' {
+			// scp-like syntax with no directory, such as host:repo.git
+			fetch += ":"
+		}
+
+		remote, ok := remotes[fetch]
+		if !ok {
+			remote = strings.Trim(strings.NewReplacer("/", "-", ":", "-").Replace(normalizeRemoteURL(fetch)), "-")
+			remotes[fetch] = remote
+			m.Remotes = append(m.Remotes, manifestRemote{Name: remote, Fetch: fetch})
+		}
+
+		m.Projects = append(m.Projects, manifestProject{Name: name, Path: r.path, Remote: remote, Revision: r.branch})
+	}
+
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell when it needs it.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`;&|<>()*?[]#~") {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
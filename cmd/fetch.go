@@ -14,39 +14,45 @@
 package cmd
 
 import (
-	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"context"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+var fetchPrune bool
+
 // fetchCmd represents the fetch command
 var fetchCmd = &cobra.Command{
 	Use:   "fetch directory",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Short: "Fetch the repositories beneath a directory",
+	Long: `Fetch runs git fetch in a repository, or with -r in every repository beneath
+a directory. Repositories without a remote are skipped. With --prune,
+remote-tracking branches deleted on the remote are removed; prune-gone goes
+on to delete the merged local branches they leave behind.`,
+	ValidArgsFunction: completeDirectory,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return errors.New("directory argument is required")
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
 		}
-		if recursive {
-			return fetchWalk(args[0])
+		if err := sshPreflight(ctx, args[0]); err != nil {
+			return err
 		}
-		return fetch(args[0])
+		return runOperation(ctx, args[0], fetch)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(fetchCmd)
+	describe(fetchCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Fetch every repository beneath ~/src", "got fetch -r ~/src"},
+			{"Fetch with a progress line, stopping at the first failure", "got fetch -r -p --fail-fast ~/src"},
+		},
+	})
 
 	// Here you will define your flags and configuration settings.
 
@@ -58,43 +64,36 @@ func init() {
 	// is called directly, e.g.:
 	// fetchCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	fetchCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively fetch subdirectories listed")
+	fetchCmd.Flags().DurationVar(&timeBudget, "time-budget", 0, "With -r, stop starting repositories after this long, most stale first, and keep the rest for got retry --remaining")
+	fetchCmd.Flags().BoolVar(&fetchPrune, "prune", false, "Remove remote-tracking branches that no longer exist on the remote")
 }
 
-func fetch(path string) error {
+func fetch(ctx context.Context, path string) error {
 
-	_, err := os.Stat(filepath.Join(path, ".git"))
-	if err != nil {
+	if !isRepository(path) {
 		if recursive {
 			return nil
 		}
-		return errors.Wrapf(err, "[%s] is not a git repository", path)
+		return errors.Errorf("[%s] is not a git repository", path)
 	}
 
-	fetchCmd := exec.Command("git", fmt.Sprintf("--work-tree=%s", path), fmt.Sprintf("--git-dir=%s", filepath.Join(path, ".git")), "fetch")
+	if !hasRemote(ctx, path) {
+		repoSkipped(path, "Skipped (no remote)")
+		return nil
+	}
 
-	if err := fetchCmd.Run(); err != nil {
-		log.Printf("[%s]: ERROR %v\n", path, err)
+	args := []string{"fetch"}
+	if fetchPrune {
+		args = append(args, "--prune")
+	}
+	if err := runner.Run(ctx, path, repoCapture(path), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, err)
 	} else {
-		log.Printf("[%s]:  Success\n", path)
+		repoSucceeded(path, "Success")
 	}
 
 	return nil
 }
-
-func fetchWalk(path string) error {
-
-	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-
-		if err != nil {
-			return errors.Wrapf(err, "error walking filepath [%s]", path)
-		}
-
-		if !info.IsDir() {
-			return nil
-		} else if filepath.Base(path) == ".git" {
-			return filepath.SkipDir
-		}
-
-		return fetch(path)
-	})
-}
@@ -0,0 +1,181 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// gitCommand returns a git command that operates on the repository at path.
+// The command is killed if ctx is cancelled before it completes.
+func gitCommand(ctx context.Context, path string, args ...string) *exec.Cmd {
+	if git.IsBare(path) {
+		args = append([]string{fmt.Sprintf("--git-dir=%s", gitPathArg(path))}, args...)
+	} else {
+		args = append([]string{fmt.Sprintf("--work-tree=%s", gitPathArg(path)), fmt.Sprintf("--git-dir=%s", gitPathArg(gitDir(path)))}, args...)
+	}
+	return gitExec(ctx, path, args...)
+}
+
+// gitPathArg returns path as --work-tree and --git-dir are given it:
+// absolute, so that a drive-relative Windows path such as C:src isn't taken
+// relative to the wrong directory, and with forward slashes, which git
+// reads the same for drive letters and UNC shares, as in //server/share.
+func gitPathArg(path string) string {
+	return filepath.ToSlash(absPath(path))
+}
+
+// gitExec returns a git command with args, logged as being for path, run
+// with the gitPath binary of the config file and its gitGlobalArgs ahead
+// of args:
+//
+//	gitPath: /opt/homebrew/bin/git
+//	gitGlobalArgs: [-c, protocol.version=2]
+func gitExec(ctx context.Context, path string, args ...string) *exec.Cmd {
+
+	name := viper.GetString("gitPath")
+	if name == "" {
+		name = "git"
+	}
+	args = append(viper.GetStringSlice("gitGlobalArgs"), args...)
+
+	logCommand(path, name, args)
+	cmd := exec.CommandContext(ctx, expandHome(name), args...)
+	// Helpers git starts, such as ssh, can outlive it holding its output
+	// open; once ctx is done they aren't waited for long.
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// logCommand logs the command about to run in path at debug level, which
+// -v selects.
+func logCommand(path, name string, args []string) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	line := name
+	for _, arg := range args {
+		line += " " + shellQuote(arg)
+	}
+	logger.Debug(fmt.Sprintf("  [%s]: %s", styles.Path(displayName(path)), styles.Muted(line)), "path", path, "command", line)
+}
+
+// gitDir returns the git directory of the working tree at path. When it
+// can't be resolved the conventional .git is returned and git itself gets
+// to report the problem.
+func gitDir(path string) string {
+	if git.IsBare(path) {
+		return path
+	}
+	if dir, err := git.GitDir(path); err == nil {
+		return dir
+	}
+	return filepath.Join(path, ".git")
+}
+
+// commonDir returns the git directory the repository at path shares with
+// all of its linked worktrees, and whether path is itself one of those
+// linked worktrees rather than the main checkout.
+func commonDir(ctx context.Context, path string) (string, bool, error) {
+	out, err := gitOutput(ctx, path, "rev-parse", "--git-dir", "--git-common-dir")
+	if err != nil {
+		return "", false, errors.Wrapf(err, "unable to find the git directory of [%s]", path)
+	}
+	dirs := strings.Split(out, "\n")
+	if len(dirs) != 2 {
+		return "", false, errors.Errorf("unable to find the git directory of [%s]", path)
+	}
+	for i, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(path, dir)
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		dirs[i] = filepath.Clean(dir)
+	}
+	return dirs[1], dirs[0] != dirs[1], nil
+}
+
+// gitOutput runs git in the repository at path and returns its trimmed
+// standard output.
+func gitOutput(ctx context.Context, path string, args ...string) (string, error) {
+	out, err := gitCommand(ctx, path, args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// hasRemote reports whether the repository at path has any remote
+// configured.
+func hasRemote(ctx context.Context, path string) bool {
+	remotes, err := gitOutput(ctx, path, "remote")
+	return err == nil && remotes != ""
+}
+
+// remoteURL returns the URL of the origin remote of the repository at path.
+func remoteURL(ctx context.Context, path string) (string, error) {
+	return gitOutput(ctx, path, "config", "--get", "remote.origin.url")
+}
+
+// normalizeRemoteURL reduces the different spellings of a remote URL to a
+// comparable host/path form, so that git@github.com:foo/bar.git and
+// https://github.com/foo/bar refer to the same repository.
+func normalizeRemoteURL(url string) string {
+	url = strings.TrimSpace(url)
+	url = strings.TrimRight(url, `/\`)
+	url = strings.TrimSuffix(url, ".git")
+
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if windowsPath(url) {
+		url = strings.Replace(url, `\`, "/", -1)
+	} else if filepath.IsAbs(url) {
+		url = filepath.ToSlash(url)
+	} else if i := strings.Index(url, ":"); i >= 0 {
+		// scp-like syntax: [user@]host:path
+		url = url[:i] + "/" + strings.TrimPrefix(url[i+1:], "/")
+	}
+
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.Index(url+"/", "/") {
+		url = url[i+1:]
+	}
+
+	return strings.ToLower(url)
+}
+
+// windowsPath reports whether url is a local path on a Windows drive or
+// share, such as C:\src, C:src or \\server\share, which git takes for a path
+// rather than an scp-like URL. A single letter before the colon is taken
+// for a drive rather than a host wherever got runs, so that remotes read
+// from the same repository compare equal on every system.
+func windowsPath(url string) bool {
+	if strings.HasPrefix(url, `\\`) {
+		return true
+	}
+	if len(url) < 2 || url[1] != ':' {
+		return false
+	}
+	c := url[0]
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,356 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// goGitRunner answers read-only queries in process with go-git and hands
+// everything else to fallback: plain status and branch, and the
+// status --porcelain=v2 --branch that recursive runs summarize. Queries
+// limited to paths go to fallback too.
+type goGitRunner struct {
+	fallback commandRunner
+}
+
+func (r goGitRunner) Run(ctx context.Context, path string, out io.Writer, args ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if out == nil {
+		out = ioutil.Discard
+	}
+
+	switch strings.Join(args, " ") {
+	case "status":
+		logCommand(path, "go-git", args)
+		return goGitStatus(path, out)
+	case "status --porcelain=v2 --branch":
+		logCommand(path, "go-git", args)
+		return goGitPorcelainStatus(path, out)
+	case "branch":
+		logCommand(path, "go-git", args)
+		return goGitBranch(path, out)
+	}
+
+	return r.fallback.Run(ctx, path, out, args...)
+}
+
+// goGitOpen opens the repository at path. A linked worktree keeps its HEAD
+// and index in its own git directory and everything else in the common one
+// of its repository, which go-git only reads when asked to.
+func goGitOpen(path string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+}
+
+func goGitStatus(path string, out io.Writer) error {
+
+	repo, err := goGitOpen(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open [%s]", path)
+	}
+
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		fmt.Fprintln(out, "No commits yet")
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "unable to resolve HEAD in [%s]", path)
+	}
+
+	if head.Name().IsBranch() {
+		fmt.Fprintf(out, "On branch %s\n", head.Name().Short())
+		upstream, ahead, behind, err := goGitAheadBehind(repo, head)
+		if err == nil && upstream != "" {
+			if ahead == 0 && behind == 0 {
+				fmt.Fprintf(out, "Your branch is up to date with '%s'\n", upstream)
+			} else {
+				fmt.Fprintf(out, "Your branch is %d ahead, %d behind '%s'\n", ahead, behind, upstream)
+			}
+		}
+	} else {
+		fmt.Fprintf(out, "HEAD detached at %s\n", head.Hash().String()[:7])
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "unable to open worktree of [%s]", path)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return errors.Wrapf(err, "unable to read status of [%s]", path)
+	}
+
+	if status.IsClean() {
+		fmt.Fprintln(out, "nothing to commit, working tree clean")
+		return nil
+	}
+
+	fmt.Fprint(out, status.String())
+	return nil
+}
+
+// goGitPorcelainStatus writes the status of the repository at path in the
+// form of git status --porcelain=v2 --branch. go-git doesn't keep file
+// modes and object names in its status, so those fields are zeros.
+func goGitPorcelainStatus(path string, out io.Writer) error {
+
+	repo, err := goGitOpen(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open [%s]", path)
+	}
+
+	head, err := repo.Head()
+	switch {
+	case err == plumbing.ErrReferenceNotFound:
+		fmt.Fprintln(out, "# branch.oid (initial)")
+		if ref, err := repo.Reference(plumbing.HEAD, false); err == nil {
+			fmt.Fprintf(out, "# branch.head %s\n", ref.Target().Short())
+		}
+	case err != nil:
+		return errors.Wrapf(err, "unable to resolve HEAD in [%s]", path)
+	case head.Name().IsBranch():
+		fmt.Fprintf(out, "# branch.oid %s\n", head.Hash())
+		fmt.Fprintf(out, "# branch.head %s\n", head.Name().Short())
+		upstream, ahead, behind, err := goGitAheadBehind(repo, head)
+		if err == nil && upstream != "" {
+			fmt.Fprintf(out, "# branch.upstream %s\n", upstream)
+			fmt.Fprintf(out, "# branch.ab +%d -%d\n", ahead, behind)
+		}
+	default:
+		fmt.Fprintf(out, "# branch.oid %s\n", head.Hash())
+		fmt.Fprintln(out, "# branch.head (detached)")
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "unable to open worktree of [%s]", path)
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return errors.Wrapf(err, "unable to read status of [%s]", path)
+	}
+
+	files := make([]string, 0, len(status))
+	for file := range status {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	const zero = "0000000000000000000000000000000000000000"
+	code := func(c git.StatusCode) byte {
+		if c == git.Unmodified {
+			return '.'
+		}
+		return byte(c)
+	}
+	for _, file := range files {
+		fs := status[file]
+		switch {
+		case fs.Staging == git.Untracked || fs.Worktree == git.Untracked:
+			fmt.Fprintf(out, "? %s\n", file)
+		case fs.Staging == git.UpdatedButUnmerged || fs.Worktree == git.UpdatedButUnmerged:
+			fmt.Fprintf(out, "u UU N... 000000 000000 000000 000000 %s %s %s %s\n", zero, zero, zero, file)
+		case fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified:
+		default:
+			fmt.Fprintf(out, "1 %c%c N... 000000 000000 000000 %s %s %s\n", code(fs.Staging), code(fs.Worktree), zero, zero, file)
+		}
+	}
+
+	return nil
+}
+
+func goGitBranch(path string, out io.Writer) error {
+
+	repo, err := goGitOpen(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open [%s]", path)
+	}
+
+	current := ""
+	if head, err := repo.Head(); err == nil {
+		current = head.Name().Short()
+	}
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return errors.Wrapf(err, "unable to list branches of [%s]", path)
+	}
+
+	return branches.ForEach(func(ref *plumbing.Reference) error {
+		marker := " "
+		if ref.Name().Short() == current {
+			marker = "*"
+		}
+		fmt.Fprintf(out, "%s %s\n", marker, ref.Name().Short())
+		return nil
+	})
+}
+
+// goGitAheadBehind returns the upstream of head and how many commits head
+// is ahead of and behind it. An empty upstream means none is configured.
+func goGitAheadBehind(repo *git.Repository, head *plumbing.Reference) (string, int, int, error) {
+
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	branch, ok := cfg.Branches[head.Name().Short()]
+	if !ok || branch.Remote == "" || branch.Merge == "" {
+		return "", 0, 0, nil
+	}
+
+	upstreamName := plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short())
+	upstream, err := repo.Reference(upstreamName, true)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	ahead, behind, err := aheadBehind(repo, head.Hash(), upstream.Hash())
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return upstreamName.Short(), ahead, behind, nil
+}
+
+// aheadBehind counts the commits reachable from local but not upstream,
+// and from upstream but not local. Like git, it walks both histories at
+// once, newest commit first, and stops once everything left to walk is
+// reachable from both and older than any commit counted, so that only the
+// commits since the merge base are read rather than the whole history.
+func aheadBehind(repo *git.Repository, local, upstream plumbing.Hash) (int, int, error) {
+
+	const (
+		fromLocal = 1 << iota
+		fromUpstream
+		fromBoth = fromLocal | fromUpstream
+	)
+
+	paint := map[plumbing.Hash]int{}
+	queue := &commitQueue{}
+	// pending is how many commits in the queue aren't yet known to be
+	// reachable from both; once there are none the walk is over.
+	pending := 0
+
+	add := func(hash plumbing.Hash, from int) error {
+		old := paint[hash]
+		if old|from == old {
+			return nil
+		}
+		paint[hash] = old | from
+		if queue.has(hash) {
+			if old|from == fromBoth {
+				pending--
+			}
+			return nil
+		}
+		// A commit walked already is walked again, to carry the new
+		// paint on to its parents.
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, c)
+		if old|from != fromBoth {
+			pending++
+		}
+		return nil
+	}
+
+	if err := add(local, fromLocal); err != nil {
+		return 0, 0, err
+	}
+	if err := add(upstream, fromUpstream); err != nil {
+		return 0, 0, err
+	}
+
+	// oldest is when the oldest commit walked that is reachable from only
+	// one side was made. A commit still queued that is as new may be its
+	// descendant, and reaching it from the other side after all.
+	var oldest time.Time
+	for queue.Len() > 0 && (pending > 0 || !oldest.IsZero() && !queue.commits[0].Committer.When.Before(oldest)) {
+		c := heap.Pop(queue).(*object.Commit)
+		from := paint[c.Hash]
+		if from != fromBoth {
+			pending--
+			if oldest.IsZero() || c.Committer.When.Before(oldest) {
+				oldest = c.Committer.When
+			}
+		}
+		for _, parent := range c.ParentHashes {
+			if err := add(parent, from); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	ahead, behind := 0, 0
+	for _, from := range paint {
+		switch from {
+		case fromLocal:
+			ahead++
+		case fromUpstream:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// commitQueue is a heap of commits, the most recently committed first.
+type commitQueue struct {
+	commits []*object.Commit
+	queued  map[plumbing.Hash]bool
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+func (q *commitQueue) Less(i, j int) bool {
+	return q.commits[i].Committer.When.After(q.commits[j].Committer.When)
+}
+func (q *commitQueue) Swap(i, j int) { q.commits[i], q.commits[j] = q.commits[j], q.commits[i] }
+
+func (q *commitQueue) Push(x interface{}) {
+	c := x.(*object.Commit)
+	if q.queued == nil {
+		q.queued = map[plumbing.Hash]bool{}
+	}
+	q.queued[c.Hash] = true
+	q.commits = append(q.commits, c)
+}
+
+func (q *commitQueue) Pop() interface{} {
+	c := q.commits[len(q.commits)-1]
+	q.commits = q.commits[:len(q.commits)-1]
+	delete(q.queued, c.Hash)
+	return c
+}
+
+func (q *commitQueue) has(hash plumbing.Hash) bool { return q.queued[hash] }
@@ -0,0 +1,88 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRun runs git in dir and fails the test if it fails.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Env = append(c.Environ(), "GIT_AUTHOR_NAME=got", "GIT_AUTHOR_EMAIL=got@example.com",
+		"GIT_COMMITTER_NAME=got", "GIT_COMMITTER_EMAIL=got@example.com", "GIT_CONFIG_GLOBAL=/dev/null")
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// linkedWorktree creates a repository with a commit of two files on main and a linked
+// worktree of it on feature, and returns the path of the worktree.
+func linkedWorktree(t *testing.T) string {
+	t.Helper()
+	root := initRepos(t, 1)
+	repo := filepath.Join(root, "a")
+	gitRun(t, repo, "checkout", "-q", "-b", "main")
+	for _, name := range []string{"README", "main.go"} {
+		if err := ioutil.WriteFile(filepath.Join(repo, name), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-q", "-m", "first")
+	worktree := filepath.Join(root, "a-feature")
+	gitRun(t, repo, "worktree", "add", "-q", "-b", "feature", worktree)
+	return worktree
+}
+
+func TestGoGitLinkedWorktree(t *testing.T) {
+	worktree := linkedWorktree(t)
+	runner := goGitRunner{fallback: execRunner{}}
+
+	var status bytes.Buffer
+	if err := runner.Run(context.Background(), worktree, &status, "status"); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	want := "On branch feature\nnothing to commit, working tree clean\n"
+	if status.String() != want {
+		t.Errorf("status = %q, want %q", status.String(), want)
+	}
+
+	var porcelain bytes.Buffer
+	if err := runner.Run(context.Background(), worktree, &porcelain, "status", "--porcelain=v2", "--branch"); err != nil {
+		t.Fatalf("status --porcelain=v2: %v", err)
+	}
+	if got, want := porcelain.String(), gitRun(t, worktree, "status", "--porcelain=v2", "--branch"); got != want {
+		t.Errorf("porcelain status = %q, want %q as git gives it", got, want)
+	}
+
+	var branches bytes.Buffer
+	if err := runner.Run(context.Background(), worktree, &branches, "branch"); err != nil {
+		t.Fatalf("branch: %v", err)
+	}
+	if !strings.Contains(branches.String(), "* feature") {
+		t.Errorf("branch = %q, want feature current", branches.String())
+	}
+}
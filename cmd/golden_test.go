@@ -0,0 +1,160 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"text/template"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the output the tests get")
+
+// golden compares got with testdata/name, or with -update writes it there.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// withResults sets the results of the run for the rest of the test to a
+// pull in four repositories: one updated, one up to date, one skipped and
+// one failed, with output for two of them.
+func withResults(t *testing.T) summary {
+	withStyler(t, plainStyler{})
+	t.Setenv("COLUMNS", "")
+
+	saved, savedVersion := results, formatVersion
+	formatVersion = formatVersionLatest
+	results = []result{
+		{Type: "repository", Version: formatVersion, Path: "/src/api", Operation: "pull", Status: statusFailed,
+			Error: "unable to pull: merge conflict in go.mod", Output: "CONFLICT (content): Merge conflict in go.mod\n", Duration: 0.25, branch: "main"},
+		{Type: "repository", Version: formatVersion, Path: "/src/web", Operation: "pull", Status: statusSuccess,
+			Detail: "Fast-forwarded", Output: "Updating 1a2b3c4..5d6e7f8\nFast-forward\n README.md | 2 +-\n", Duration: 1.5, branch: "main"},
+		{Type: "repository", Version: formatVersion, Path: "/src/docs", Operation: "pull", Status: statusSkipped,
+			Detail: "Skipped (no remote)", Duration: 0.002, branch: "gh-pages"},
+		{Type: "repository", Version: formatVersion, Path: "/src/cli", Operation: "pull", Status: statusSuccess,
+			Detail: "Up to date", Duration: 0.1, branch: "main"},
+	}
+	t.Cleanup(func() { results, formatVersion = saved, savedVersion })
+
+	return summary{Type: "summary", Version: formatVersion, Operation: "pull", Total: 4, Succeeded: 2, Skipped: 1, Failed: 1, Duration: 1.852}
+}
+
+// captureMachineOutput returns what fn writes with machineWriter.
+func captureMachineOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := ioutil.TempFile(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := outputFile
+	outputFile = f
+	defer func() { outputFile = saved }()
+
+	fn()
+	f.Close()
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGoldenTable(t *testing.T) {
+	withResults(t)
+	golden(t, "table.golden", captureStdout(t, writeResultsTable))
+}
+
+func TestGoldenJSON(t *testing.T) {
+	s := withResults(t)
+	golden(t, "json.golden", captureMachineOutput(t, func() {
+		for _, r := range results {
+			writeJSON(r)
+		}
+		writeJSON(s)
+	}))
+}
+
+func TestGoldenPorcelain(t *testing.T) {
+	withResults(t)
+	porcelainHeader = sync.Once{}
+	golden(t, "porcelain.golden", captureMachineOutput(t, func() {
+		for _, r := range results {
+			writePorcelain(r)
+		}
+	}))
+}
+
+func TestGoldenCSV(t *testing.T) {
+	withResults(t)
+	csvHeader, csvWriter = sync.Once{}, nil
+	golden(t, "csv.golden", captureMachineOutput(t, func() {
+		for _, r := range results {
+			writeCSV(r)
+		}
+	}))
+}
+
+func TestGoldenTemplate(t *testing.T) {
+	withResults(t)
+	saved := lineTemplate
+	lineTemplate = template.Must(template.New("format").Funcs(templateFuncs).Parse(`{{.Name}} {{.Branch}} {{upper .Status}} {{.Detail}}{{.Error}} {{.Duration}}`))
+	defer func() { lineTemplate = saved }()
+	golden(t, "template.golden", captureMachineOutput(t, func() {
+		for _, r := range results {
+			writeTemplate(r)
+		}
+	}))
+}
+
+func TestGoldenMarkdown(t *testing.T) {
+	s := withResults(t)
+	var b bytes.Buffer
+	writeMarkdownReport(&b, s)
+	golden(t, "markdown.golden", b.String())
+}
+
+func TestGoldenJUnit(t *testing.T) {
+	s := withResults(t)
+	var b bytes.Buffer
+	if err := writeJUnit(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	// The report is stamped with the time the run started.
+	stamp := regexp.MustCompile(`timestamp="[^"]*"`)
+	golden(t, "junit.golden", stamp.ReplaceAllString(b.String(), `timestamp="2006-01-02T15:04:05"`))
+}
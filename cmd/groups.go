@@ -0,0 +1,323 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// group is a set of directory trees that share settings, read from the
+// groups section of the config file:
+//
+//	skip: [archive]
+//	groups:
+//	  work:
+//	    paths: [~/src/acme/*]
+//	    skip: [legacy-*]
+//	    failFast: true
+//	    jobs: 8
+//	    hooks:
+//	      preRepo: make -s check
+//	  oss:
+//	    paths: [~/src/oss]
+//	    hidden: false
+//	    maxFailures: 5
+//	    ifBehind: true
+//	  tools: [~/src/tools, ~/go/src]
+//
+// A group given as a list has those paths and no settings of its own.
+// Paths can be globs. Skip patterns are added to the top-level skip list
+// for the repositories in the group. The other settings take the place of
+// the flag of the same name when a run starts inside the group and the flag
+// isn't given, ifBehind for pull only; unset ones keep the top-level value.
+// Hooks take the place of the hooks of the same name in the hooks section:
+// the preRepo and postRepo hooks of a repository's group run in it, and the
+// postRun hook of the group a run starts in runs after it.
+//
+// A group can be given in place of a directory as @name, such as
+// got pull @work, to run recursively in all of its paths.
+type group struct {
+	Paths       []string          `mapstructure:"paths"`
+	Skip        []string          `mapstructure:"skip"`
+	Hidden      *bool             `mapstructure:"hidden"`
+	MaxDepth    *int              `mapstructure:"maxDepth"`
+	MaxFailures *int              `mapstructure:"maxFailures"`
+	FailFast    *bool             `mapstructure:"failFast"`
+	IfBehind    *bool             `mapstructure:"ifBehind"`
+	Jobs        *int              `mapstructure:"jobs"`
+	Hooks       map[string]string `mapstructure:"hooks"`
+}
+
+// groups returns the groups in the config file by name. A malformed group
+// is reported and otherwise ignored.
+func groups() map[string]group {
+
+	gs := map[string]group{}
+	for name, v := range viper.GetStringMap("groups") {
+		var g group
+		if paths, ok := v.([]interface{}); ok {
+			for _, p := range paths {
+				g.Paths = append(g.Paths, fmt.Sprint(p))
+			}
+		} else if err := viper.UnmarshalKey("groups."+name, &g); err != nil {
+			logger.Warn(fmt.Sprintf("ignoring malformed group %s in config file: %s", name, err))
+			continue
+		}
+		gs[name] = g
+	}
+	return gs
+}
+
+// resolvedGroups are the groups of the config file with their globs
+// expanded, so that they are read once for a run rather than for every
+// repository in it.
+type resolvedGroups struct {
+	byName map[string]group
+	paths  map[string][]string
+}
+
+// runGroups are the groups of the run in progress, set by runOperation. It
+// is nil outside of a run.
+var runGroups *resolvedGroups
+
+// resolveGroups reads the groups in the config file and expands their paths.
+func resolveGroups() *resolvedGroups {
+
+	rg := &resolvedGroups{byName: groups(), paths: map[string][]string{}}
+	for name, g := range rg.byName {
+		rg.paths[name] = groupPaths(g)
+	}
+	return rg
+}
+
+// currentGroups returns the groups of the run in progress, or resolves them
+// afresh outside of a run.
+func currentGroups() *resolvedGroups {
+	if runGroups != nil {
+		return runGroups
+	}
+	return resolveGroups()
+}
+
+// groupArg returns the group named by an @name argument.
+func groupArg(arg string) (string, group, bool, error) {
+
+	if !strings.HasPrefix(arg, "@") {
+		return "", group{}, false, nil
+	}
+	name := strings.TrimPrefix(arg, "@")
+	gs := currentGroups().byName
+	if g, ok := gs[strings.ToLower(name)]; ok {
+		return name, g, true, nil
+	}
+
+	names := make([]string, 0, len(gs))
+	for n := range gs {
+		names = append(names, "@"+n)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "", group{}, false, usageErrorf("unknown group [%s], none are configured", arg)
+	}
+	return "", group{}, false, usageErrorf("unknown group [%s], expected one of %s", arg, strings.Join(names, ", "))
+}
+
+// groupPaths returns the directories of g, its globs expanded.
+func groupPaths(g group) []string {
+
+	var paths []string
+	for _, p := range g.Paths {
+		p = absPath(expandHome(p))
+		matches, err := filepath.Glob(p)
+		if err != nil || matches == nil {
+			// A path that isn't there yet still belongs to the group.
+			paths = append(paths, p)
+			continue
+		}
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.IsDir() {
+				paths = append(paths, m)
+			}
+		}
+	}
+	return paths
+}
+
+// groupOf returns the name and settings of the group whose paths hold path,
+// or of the group path names as @name. When groups are nested the deepest
+// path wins.
+func groupOf(path string) (string, group, bool) {
+
+	if name, g, ok, _ := groupArg(path); ok {
+		return name, g, true
+	}
+
+	abs := absPath(path)
+
+	rg := currentGroups()
+	var name string
+	var found group
+	longest := -1
+	for n, g := range rg.byName {
+		for _, p := range rg.paths[n] {
+			if within(abs, []string{p}) && len(p) > longest {
+				name, found, longest = n, g, len(p)
+			}
+		}
+	}
+	return name, found, longest >= 0
+}
+
+// applyGroup takes the settings of the group root is in for the run, except
+// those given as flags on the command line.
+func applyGroup(root string) {
+
+	runHooks = nil
+	name, g, ok := groupOf(root)
+	if !ok {
+		return
+	}
+	runHooks = g.Hooks
+
+	flags := RootCmd.PersistentFlags()
+	if g.Hidden != nil && !flags.Changed("hidden") {
+		viper.Set("hidden", *g.Hidden)
+	}
+	if g.MaxDepth != nil && !flags.Changed("max-depth") {
+		maxDepth = *g.MaxDepth
+	}
+	if g.MaxFailures != nil && !flags.Changed("max-failures") {
+		maxFailures = *g.MaxFailures
+	}
+	if g.FailFast != nil && !flags.Changed("fail-fast") {
+		failFast = *g.FailFast
+	}
+	if g.Jobs != nil && !flags.Changed("jobs") {
+		if *g.Jobs < 1 {
+			logger.Warn(fmt.Sprintf("ignoring jobs of group %s, which must be at least 1", name))
+		} else {
+			jobs = *g.Jobs
+		}
+	}
+}
+
+// skipPattern returns the first skip pattern, top-level or from path's
+// group, that matches the repository at path.
+func skipPattern(path string) (string, bool) {
+
+	patterns := viper.GetStringSlice("skip")
+	if _, g, ok := groupOf(path); ok {
+		patterns = append(patterns, g.Skip...)
+	}
+
+	for _, pattern := range patterns {
+		if matchPattern(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// included reports whether the repository at path is to be worked on given
+// the includeList in the config file, or --only: with an include list only
+// repositories matching one of its patterns are, and with none every
+// repository is. Patterns are matched as skip patterns are.
+func included(path string) bool {
+
+	patterns := viper.GetStringSlice("includeList")
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matchPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// originIncluded reports whether the repository at path is to be worked on
+// given the originList in the config file, or --origin: with an origin list
+// only repositories whose origin remote matches one of its patterns are, and
+// with none every repository is. Patterns are matched against the remote as
+// host/path, such as github.com/acme/api, or against its leading part, so
+// that github.com matches every repository on that host and github.com/acme/*
+// or github.com/acme every repository of that organization.
+func originIncluded(ctx context.Context, path string) bool {
+
+	patterns := viper.GetStringSlice("originList")
+	if len(patterns) == 0 {
+		return true
+	}
+	remote, err := remoteURL(ctx, path)
+	if err != nil || remote == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matchOrigin(pattern, normalizeRemoteURL(remote)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin reports whether pattern matches remote, a normalized remote
+// URL, or one of its leading parts.
+func matchOrigin(pattern, remote string) bool {
+	pattern = normalizeRemoteURL(pattern)
+	parts := strings.Split(remote, "/")
+	for i := len(parts); i > 0; i-- {
+		if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern reports whether pattern matches the repository at dir: its
+// directory name or, when pattern contains a /, its path. On Windows a \
+// works as well as a /, as in C:\src\legacy-*, and case is ignored. Paths
+// are matched with forward slashes so that * stops at a directory either
+// way.
+func matchPattern(pattern, dir string) bool {
+	if strings.Contains(pattern, "/") || strings.Contains(pattern, string(filepath.Separator)) {
+		pattern = expandHome(pattern)
+	}
+	return matchPathPattern(pattern, absPath(dir), runtime.GOOS == "windows")
+}
+
+// matchPathPattern is matchPattern for an absolute dir, with paths taken as
+// Windows ones when windows is set.
+func matchPathPattern(pattern, dir string, windows bool) bool {
+	if windows {
+		pattern = strings.ToLower(strings.Replace(pattern, `\`, "/", -1))
+		dir = strings.ToLower(strings.Replace(dir, `\`, "/", -1))
+	}
+	target := path.Base(dir)
+	if strings.Contains(pattern, "/") {
+		target = dir
+	}
+	ok, _ := path.Match(pattern, target)
+	return ok
+}
@@ -0,0 +1,107 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// patternSeeds are patterns and paths to start fuzzing the skip patterns
+// from: Unicode, both separators, .. and very long segments.
+var patternSeeds = []struct{ pattern, dir string }{
+	{"legacy-*", "/src/legacy-app"},
+	{"/src/legacy-*", "/src/acme/../legacy-app"},
+	{"vendor", "/src/app/vendor/"},
+	{"日本*", "/src/日本語"},
+	{"*\u0301", "/src/cafe\u0301"},
+	{`C:\src\*`, `C:\src\app`},
+	{`\\server\share\*`, `\\server\share\repo`},
+	{"[a-", "/src/a"},
+	{"[^/]*", "/src/app"},
+	{"\\", "/src/\\"},
+	{"*/..", "/src/.."},
+	{strings.Repeat("a", 255) + "*", "/" + strings.Repeat("a", 1024)},
+	{"\xff*", "/src/\xff\xfe"},
+}
+
+// escapeGlob returns a pattern matching name and nothing else.
+func escapeGlob(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func FuzzMatchPattern(f *testing.F) {
+	for _, s := range patternSeeds {
+		f.Add(s.pattern, s.dir)
+	}
+	f.Fuzz(func(t *testing.T, pattern, dir string) {
+		got := matchPattern(pattern, dir)
+		matchPathPattern(pattern, dir, true)
+
+		// A path is matched once .. and repeated separators are resolved,
+		// however it is written.
+		if again := matchPattern(pattern, filepath.Join(dir, "x", "..")); again != got {
+			t.Errorf("matchPattern(%q, %q) = %v, but %v with x/.. added", pattern, dir, got, again)
+		}
+
+		target := path.Clean("/" + dir)
+		name := path.Base(target)
+		if name == "/" || strings.ContainsRune(name, '\uFFFD') {
+			return
+		}
+		// A repository is always matched by its own name, spelled out, and
+		// by *.
+		if escaped := escapeGlob(name); !matchPathPattern(escaped, target, false) {
+			t.Errorf("matchPathPattern(%q, %q) = false, want the name to match itself", escaped, target)
+		}
+		if !matchPathPattern("*", target, false) {
+			t.Errorf("matchPathPattern(%q, %q) = false, want * to match every name", "*", target)
+		}
+		// * doesn't reach across directories.
+		if parent := path.Dir(target); parent != "/" && matchPathPattern(parent+"/*", target+"/deeper", false) {
+			t.Errorf("matchPathPattern(%q, %q) = true, want * to stop at a /", parent+"/*", target+"/deeper")
+		}
+	})
+}
+
+func FuzzSkipPattern(f *testing.F) {
+	for _, s := range patternSeeds {
+		f.Add(s.pattern, s.dir)
+	}
+	saved := viper.Get("skip")
+	defer viper.Set("skip", saved)
+
+	f.Fuzz(func(t *testing.T, pattern, dir string) {
+		viper.Set("skip", []string{pattern})
+		got, ok := skipPattern(dir)
+		if want := matchPattern(pattern, dir); ok != want {
+			t.Errorf("skipPattern(%q) with skip [%q] = %v, want %v", dir, pattern, ok, want)
+		}
+		if ok && got != pattern {
+			t.Errorf("skipPattern(%q) = %q, want the pattern that matched, %q", dir, got, pattern)
+		}
+	})
+}
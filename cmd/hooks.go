@@ -0,0 +1,187 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Hooks are shell commands from the hooks section of the config file that
+// run around operations:
+//
+//	hooks:
+//	  preRepo: test -z "$(git status --porcelain)"
+//	  postRepo: '[ "$GOT_STATUS" = success ] && make -s build'
+//	  postRun: notify-send "got $GOT_OPERATION: $GOT_FAILED failed"
+//
+// preRepo runs in each repository before the operation, which fails the
+// repository rather than run when the hook fails. postRepo runs in each
+// repository after it, and postRun once the run is done, in the directory
+// got was run from. The hooks are told about the run and the repository
+// through the environment: GOT_OPERATION, GOT_REPO, GOT_REPO_NAME and
+// GOT_BRANCH for the repository hooks, GOT_STATUS, GOT_DETAIL, GOT_ERROR
+// and GOT_DURATION for postRepo, and GOT_TOTAL, GOT_SUCCEEDED,
+// GOT_SKIPPED, GOT_FAILED and GOT_DURATION for postRun. A postRepo or
+// postRun hook that fails is warned about; it doesn't change the result.
+// Groups can have hooks of their own in place of these; see group.
+const (
+	hookPreRepo  = "preRepo"
+	hookPostRepo = "postRepo"
+	hookPostRun  = "postRun"
+)
+
+// shellCommand returns a command that runs command with the shell in dir.
+func shellCommand(ctx context.Context, dir, command string) *exec.Cmd {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	logCommand(dir, shell, []string{flag, command})
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Dir = dir
+	return cmd
+}
+
+// runHooks are the hooks of the group the run started in, set by
+// applyGroup.
+var runHooks map[string]string
+
+// hookCommand returns the command of the hook called name for the
+// repository at path, or for the run as a whole when path is empty: that of
+// the group the repository or the run is in, if it has one, or else the one
+// in the hooks section. A group can turn a hook off by giving it no command.
+func hookCommand(name, path string) string {
+
+	hooks := runHooks
+	if path != "" {
+		hooks = nil
+		if _, g, ok := groupOf(path); ok {
+			hooks = g.Hooks
+		}
+	}
+	// viper reads the keys of the config file lower cased.
+	if command, ok := hooks[strings.ToLower(name)]; ok {
+		return command
+	}
+	return viper.GetString("hooks." + name)
+}
+
+// runHook runs the hook called name for the repository at path, or for the
+// run when path is empty, if one is configured, in dir with env added to the
+// environment, writing its output to out.
+func runHook(ctx context.Context, name, path, dir string, env []string, out io.Writer) error {
+
+	command := hookCommand(name, path)
+	if command == "" {
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := shellCommand(ctx, dir, command)
+	cmd.Env = append(append(os.Environ(), "GOT_OPERATION="+operation), env...)
+	cmd.Stdout = out
+	cmd.Stderr = io.MultiWriter(out, &stderr)
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.Errorf("%s hook failed: %s", name, lastLine(msg))
+		}
+		return errors.Wrapf(err, "%s hook failed", name)
+	}
+	return nil
+}
+
+// repoHookEnv returns the environment telling a repository hook about the
+// repository at path.
+func repoHookEnv(path string) []string {
+	branch, _ := git.Head(path)
+	return []string{"GOT_REPO=" + path, "GOT_REPO_NAME=" + displayName(path), "GOT_BRANCH=" + branch}
+}
+
+// preRepoHook runs the preRepo hook in the repository at path, reporting
+// whether the operation may go ahead. A failing hook fails the repository.
+func preRepoHook(ctx context.Context, path string) bool {
+	if err := runHook(ctx, hookPreRepo, path, path, repoHookEnv(path), repoCapture(path)); err != nil {
+		if ctx.Err() == nil {
+			repoFailed(path, err)
+		}
+		return false
+	}
+	return true
+}
+
+// postRepoHook runs the postRepo hook in the repository at path with the
+// result the operation reported, if it reported one.
+func postRepoHook(ctx context.Context, path string) {
+
+	if hookCommand(hookPostRepo, path) == "" {
+		return
+	}
+
+	env := repoHookEnv(path)
+	resultsMu.Lock()
+	for i := len(results) - 1; i >= 0; i-- {
+		if r := results[i]; r.Path == path {
+			env = append(env, "GOT_STATUS="+r.Status, "GOT_DETAIL="+r.Detail, "GOT_ERROR="+r.Error,
+				"GOT_DURATION="+strconv.FormatFloat(r.Duration, 'f', 3, 64))
+			break
+		}
+	}
+	resultsMu.Unlock()
+
+	if err := runHook(ctx, hookPostRepo, path, path, env, repoCapture(path)); err != nil && ctx.Err() == nil {
+		logger.Warn(fmt.Sprintf("[%s] %s", displayName(path), err), "path", path)
+	}
+}
+
+// postRunHook runs the postRun hook with the summary of the run.
+func postRunHook(s summary) {
+
+	if hookCommand(hookPostRun, "") == "" {
+		return
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+	env := []string{
+		"GOT_TOTAL=" + strconv.Itoa(s.Total),
+		"GOT_SUCCEEDED=" + strconv.Itoa(s.Succeeded),
+		"GOT_SKIPPED=" + strconv.Itoa(s.Skipped),
+		"GOT_FAILED=" + strconv.Itoa(s.Failed),
+		"GOT_DURATION=" + strconv.FormatFloat(s.Duration, 'f', 3, 64),
+	}
+
+	// The run is over, so the hook gets a moment of its own even once the
+	// run's context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := runHook(ctx, hookPostRun, "", dir, env, os.Stderr); err != nil {
+		logger.Warn(err.Error())
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	importFrom string
+	importRoot string
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import --from mrconfig|vcstool|repo file",
+	Short: "Convert another tool's list of repositories into a manifest",
+	Long: `Import reads the list of repositories of another multi-repository tool and
+writes it to stdout as the manifest section of a got config file, ready for
+got clone and got doctor:
+
+  mrconfig  an .mrconfig of myrepos; the git clone of each checkout is read
+  vcstool   a .repos file of vcstool
+  repo      a manifest of Android's repo tool, whose groups become got
+            groups
+
+Paths in the file are relative to --root, by default the directory the file
+is in, or for a repo manifest in a checkout's .repo/manifests the checkout.
+A repo remote with a relative fetch URL is resolved against the remote of
+the repository the manifest is in, as repo does.
+
+Entries that aren't git repositories, or that can't be read, are left out
+with a warning. Add the output to the config file and check it with got
+config validate.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		read, ok := importers[importFrom]
+		if !ok {
+			return usageErrorf("unknown import format [%s], expected mrconfig, vcstool or repo", importFrom)
+		}
+
+		name := expandHome(args[0])
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read [%s]", args[0])
+		}
+		root := importRoot
+		if root == "" {
+			root = defaultImportRoot(name)
+		}
+
+		imported, err := read(cmd.Context(), name, data)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse [%s]", args[0])
+		}
+
+		repos, groups := importedManifest(absPath(expandHome(root)), imported)
+		if len(repos) == 0 {
+			return errors.Errorf("[%s] lists no git repositories", args[0])
+		}
+		return writeConfigManifest(os.Stdout, fmt.Sprintf("Imported from %s by got import", absPath(name)), repos, groups)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	describe(importCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Move from myrepos to got", "got import --from mrconfig ~/src/.mrconfig >> ~/.config/got/config.yaml"},
+			{"Import a vcstool file for a workspace in ~/ros/src", "got import --from vcstool --root ~/ros/src ros2.repos"},
+			{"Import the manifest of a repo checkout", "got import --from repo ~/aosp/.repo/manifests/default.xml"},
+		},
+	})
+
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Format to read: mrconfig, vcstool or repo")
+	importCmd.Flags().StringVar(&importRoot, "root", "", "Directory the paths in the file are relative to")
+}
+
+// importedRepository is a repository as the import formats list it.
+type importedRepository struct {
+	path   string // relative to the import root, unless absolute
+	url    string
+	branch string
+	groups []string
+}
+
+var importers = map[string]func(ctx context.Context, name string, data []byte) ([]importedRepository, error){
+	"mrconfig": importMrconfig,
+	"vcstool":  importVcstool,
+	"repo":     importRepoManifest,
+}
+
+// defaultImportRoot returns the directory the paths in the file name are
+// relative to when --root isn't given.
+func defaultImportRoot(name string) string {
+	dir := filepath.Dir(absPath(name))
+	if filepath.Base(dir) == "manifests" && filepath.Base(filepath.Dir(dir)) == ".repo" {
+		return filepath.Dir(filepath.Dir(dir))
+	}
+	return dir
+}
+
+// importedManifest turns what was imported into the manifest and groups of
+// a config file, with paths beneath root. A path listed twice keeps its
+// first entry.
+func importedManifest(root string, imported []importedRepository) ([]workspaceRepo, map[string][]string) {
+
+	var repos []workspaceRepo
+	groups := map[string][]string{}
+	seen := map[string]bool{}
+	for _, r := range imported {
+		path := filepath.FromSlash(expandHome(r.path))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		path = abbreviateHome(filepath.Clean(path))
+		if seen[path] {
+			logger.Warn(fmt.Sprintf("[%s] is listed more than once; keeping the first", path), "path", path)
+			continue
+		}
+		seen[path] = true
+
+		repos = append(repos, workspaceRepo{Path: path, Remote: r.url, Branch: r.branch})
+		for _, g := range r.groups {
+			groups[g] = append(groups[g], path)
+		}
+	}
+
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Path < repos[j].Path })
+	for _, paths := range groups {
+		sort.Strings(paths)
+	}
+	return repos, groups
+}
+
+// importSkipped warns that an entry of the imported file is left out.
+func importSkipped(path, reason string) {
+	logger.Warn(fmt.Sprintf("%s [%s]:  %s", styles.Muted(iconSkipped), styles.Path(path), styles.Muted("Skipped ("+reason+")")), "path", path)
+}
+
+// commitID matches an abbreviated or full commit ID, which git clone can't
+// check out as a branch.
+var commitID = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// importBranch returns the branch or tag to clone for a revision, or an
+// empty string for one clone can't take.
+func importBranch(revision string) string {
+	revision = strings.TrimPrefix(revision, "refs/heads/")
+	revision = strings.TrimPrefix(revision, "refs/tags/")
+	if commitID.MatchString(revision) || strings.HasPrefix(revision, "refs/") {
+		return ""
+	}
+	return revision
+}
+
+// importMrconfig reads an .mrconfig: a section per repository, named by its
+// path, whose checkout command is a git clone.
+func importMrconfig(_ context.Context, _ string, data []byte) ([]importedRepository, error) {
+
+	var repos []importedRepository
+	var section, key string
+	values := map[string]string{}
+	flush := func() {
+		if section == "" || section == "DEFAULT" {
+			return
+		}
+		path := os.ExpandEnv(section)
+		checkout, ok := values["checkout"]
+		if !ok {
+			importSkipped(path, "no checkout command")
+			return
+		}
+		r, ok := parseGitClone(checkout)
+		if !ok {
+			importSkipped(path, "checkout isn't a git clone")
+			return
+		}
+		r.path = path
+		repos = append(repos, r)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			flush()
+			section, key = strings.TrimSpace(trimmed[1:len(trimmed)-1]), ""
+			values = map[string]string{}
+		case (line[0] == ' ' || line[0] == '\t') && key != "":
+			// A continuation of the value before it.
+			values[key] += "\n" + trimmed
+		default:
+			i := strings.Index(trimmed, "=")
+			if i < 0 {
+				return nil, errors.Errorf("unexpected line [%s]", trimmed)
+			}
+			key = strings.TrimSpace(trimmed[:i])
+			values[key] = strings.TrimSpace(trimmed[i+1:])
+		}
+	}
+	flush()
+	return repos, scanner.Err()
+}
+
+// cloneOptionsWithValue are the options of git clone that take a value as
+// the next argument.
+var cloneOptionsWithValue = map[string]bool{
+	"-b": true, "--branch": true, "-o": true, "--origin": true, "-c": true, "--config": true,
+	"--depth": true, "--reference": true, "--template": true, "--separate-git-dir": true,
+	"-u": true, "--upload-pack": true, "-j": true, "--jobs": true, "--filter": true,
+}
+
+// parseGitClone reads the remote and branch of the first git clone in the
+// shell commands s.
+func parseGitClone(s string) (importedRepository, bool) {
+
+	words := shellWords(s)
+	for i := 0; i+1 < len(words); i++ {
+		if words[i] != "git" || words[i+1] != "clone" {
+			continue
+		}
+		var r importedRepository
+		for j := i + 2; j < len(words); j++ {
+			w := words[j]
+			switch {
+			case w == "&&" || w == ";" || w == "||" || w == "|":
+				j = len(words)
+			case w == "-b" || w == "--branch":
+				if j+1 < len(words) {
+					r.branch = words[j+1]
+				}
+				j++
+			case strings.HasPrefix(w, "--branch="):
+				r.branch = strings.TrimPrefix(w, "--branch=")
+			case cloneOptionsWithValue[w]:
+				j++
+			case strings.HasPrefix(w, "-"):
+			case r.url == "":
+				r.url = w
+			}
+		}
+		return r, r.url != ""
+	}
+	return importedRepository{}, false
+}
+
+// shellWords splits s into words as a POSIX shell would, minus expansions.
+func shellWords(s string) []string {
+
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == ';':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			words = append(words, ";")
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// importVcstool reads a .repos file of vcstool:
+//
+//	repositories:
+//	  ros2/rclcpp:
+//	    type: git
+//	    url: https://github.com/ros2/rclcpp.git
+//	    version: rolling
+func importVcstool(_ context.Context, _ string, data []byte) ([]importedRepository, error) {
+
+	var file struct {
+		Repositories map[string]struct {
+			Type    string `yaml:"type"`
+			URL     string `yaml:"url"`
+			Version string `yaml:"version"`
+		} `yaml:"repositories"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var repos []importedRepository
+	for path, r := range file.Repositories {
+		switch {
+		case r.Type != "git":
+			importSkipped(path, r.Type+" repository")
+		case r.URL == "":
+			importSkipped(path, "no url")
+		default:
+			repos = append(repos, importedRepository{path: path, url: r.URL, branch: importBranch(r.Version)})
+		}
+	}
+	return repos, nil
+}
+
+// importRepoManifest reads a manifest of Android's repo tool. A project is
+// cloned from its remote's fetch URL joined with its name, at its revision
+// or else its remote's or the default one, and belongs to the groups it
+// lists apart from repo's own, default, notdefault and the name: and path:
+// groups every project has.
+func importRepoManifest(ctx context.Context, name string, data []byte) ([]importedRepository, error) {
+
+	var m manifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for _, inc := range m.Includes {
+		logger.Warn(fmt.Sprintf("[%s] includes %s, which isn't imported; import it as well", name, inc.Name), "path", name)
+	}
+
+	def := manifestDefault{}
+	if m.Default != nil {
+		def = *m.Default
+	}
+	remotes := map[string]manifestRemote{}
+	for _, r := range m.Remotes {
+		remotes[r.Name] = r
+	}
+	removed := map[string]bool{}
+	for _, p := range m.RemoveProjects {
+		removed[p.Name] = true
+	}
+
+	var repos []importedRepository
+	for _, p := range m.Projects {
+		path := p.Path
+		if path == "" {
+			path = p.Name
+		}
+		if removed[p.Name] {
+			continue
+		}
+
+		remoteName := p.Remote
+		if remoteName == "" {
+			remoteName = def.Remote
+		}
+		remote, ok := remotes[remoteName]
+		if !ok {
+			importSkipped(path, fmt.Sprintf("unknown remote %q", remoteName))
+			continue
+		}
+		fetch, err := resolveFetchURL(ctx, name, remote.Fetch)
+		if err != nil {
+			importSkipped(path, err.Error())
+			continue
+		}
+
+		revision := p.Revision
+		if revision == "" {
+			revision = remote.Revision
+		}
+		if revision == "" {
+			revision = def.Revision
+		}
+
+		var groups []string
+		for _, g := range strings.FieldsFunc(p.Groups, func(c rune) bool { return c == ',' || c == ' ' }) {
+			if g != "default" && g != "notdefault" && !strings.HasPrefix(g, "name:") && !strings.HasPrefix(g, "path:") {
+				groups = append(groups, strings.ToLower(g))
+			}
+		}
+
+		repos = append(repos, importedRepository{
+			path:   path,
+			url:    strings.TrimSuffix(fetch, "/") + "/" + p.Name,
+			branch: importBranch(revision),
+			groups: groups,
+		})
+	}
+	return repos, nil
+}
+
+// resolveFetchURL resolves the fetch URL of a remote of the repo manifest
+// name. A relative one, such as .., is relative to the URL the manifest
+// was cloned from: the origin of the repository it is in.
+func resolveFetchURL(ctx context.Context, name, fetch string) (string, error) {
+
+	if !strings.HasPrefix(fetch, ".") {
+		return fetch, nil
+	}
+	dir := filepath.Dir(absPath(name))
+	base, err := remoteURL(ctx, dir)
+	if err != nil || base == "" {
+		return "", errors.Errorf("relative fetch URL %s, and the manifest isn't in a repository with a remote", fetch)
+	}
+	u, err := url.Parse(strings.TrimSuffix(base, "/"))
+	if err != nil || u.Scheme == "" {
+		return "", errors.Errorf("relative fetch URL %s, and the remote of the manifest, %s, isn't a URL", fetch, base)
+	}
+	ref, err := url.Parse(fetch)
+	if err != nil {
+		return "", errors.Errorf("invalid fetch URL %s", fetch)
+	}
+	return u.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,246 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestImportMrconfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []importedRepository
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"repositories",
+			`# mr config
+[DEFAULT]
+jobs = 4
+
+[src/api]
+checkout = git clone 'git@github.com:acme/api.git' 'api'
+
+; a comment
+[src/web]
+checkout = git clone --depth 1 -b develop https://github.com/acme/web.git web
+update = git pull
+`,
+			[]importedRepository{
+				{path: "src/api", url: "git@github.com:acme/api.git"},
+				{path: "src/web", url: "https://github.com/acme/web.git", branch: "develop"},
+			},
+			false,
+		},
+		{
+			"continued checkout",
+			"[lib]\ncheckout =\n  cd /tmp &&\n  git clone --branch=v1.2 https://example.com/lib.git lib\n",
+			[]importedRepository{{path: "lib", url: "https://example.com/lib.git", branch: "v1.2"}},
+			false,
+		},
+		{
+			"not git",
+			"[svn]\ncheckout = svn co https://example.com/svn/trunk svn\n[nothing]\nupdate = true\n",
+			nil,
+			false,
+		},
+		{"bad line", "[a]\nnot a setting\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importMrconfig(context.Background(), ".mrconfig", []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importMrconfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importMrconfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGitClone(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   importedRepository
+		wantOK bool
+	}{
+		{"git clone https://example.com/a.git", importedRepository{url: "https://example.com/a.git"}, true},
+		{`git clone -o upstream --config "core.autocrlf=false" https://example.com/a.git a`, importedRepository{url: "https://example.com/a.git"}, true},
+		{"git clone --recursive --branch main git@example.com:a.git", importedRepository{url: "git@example.com:a.git", branch: "main"}, true},
+		{`git clone https://example.com/my\ repo.git`, importedRepository{url: "https://example.com/my repo.git"}, true},
+		{"mkdir -p src; git clone https://example.com/a.git; cd a", importedRepository{url: "https://example.com/a.git"}, true},
+		{"git clone && echo done", importedRepository{}, false},
+		{"git fetch https://example.com/a.git", importedRepository{}, false},
+		{"", importedRepository{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseGitClone(tt.s)
+		if ok != tt.wantOK || got.url != tt.want.url || got.branch != tt.want.branch {
+			t.Errorf("parseGitClone(%q) = %+v, %v, want %+v, %v", tt.s, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"  git   clone\turl  ", []string{"git", "clone", "url"}},
+		{`a 'b c' "d e"`, []string{"a", "b c", "d e"}},
+		{`'it''s' "say \"hi\"" 'no\escape'`, []string{"its", `say "hi"`, `no\escape`}},
+		{`a\ b c\;d`, []string{"a b", "c;d"}},
+		{"a;b ; c", []string{"a", ";", "b", ";", "c"}},
+		{`'' ""`, []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		if got := shellWords(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellWords(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestImportBranch(t *testing.T) {
+	tests := []struct {
+		revision string
+		want     string
+	}{
+		{"", ""},
+		{"main", "main"},
+		{"refs/heads/release/1.0", "release/1.0"},
+		{"refs/tags/v2.0.0", "v2.0.0"},
+		{"4b825dc", ""},
+		{"4b825dc642cb6eb9a060e54bf8d69288fbee4904", ""},
+		{"refs/changes/12/3412/1", ""},
+		{"deadbeefcafe-fix", "deadbeefcafe-fix"},
+	}
+
+	for _, tt := range tests {
+		if got := importBranch(tt.revision); got != tt.want {
+			t.Errorf("importBranch(%q) = %q, want %q", tt.revision, got, tt.want)
+		}
+	}
+}
+
+func TestImportVcstool(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []importedRepository
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"repositories",
+			`repositories:
+  ros2/rclcpp:
+    type: git
+    url: https://github.com/ros2/rclcpp.git
+    version: rolling
+  ros2/rcl:
+    type: git
+    url: https://github.com/ros2/rcl.git
+    version: 0123456789abcdef0123456789abcdef01234567
+  vendor/legacy:
+    type: svn
+    url: https://example.com/svn/legacy
+  vendor/nourl:
+    type: git
+`,
+			[]importedRepository{
+				{path: "ros2/rcl", url: "https://github.com/ros2/rcl.git"},
+				{path: "ros2/rclcpp", url: "https://github.com/ros2/rclcpp.git", branch: "rolling"},
+			},
+			false,
+		},
+		{"not yaml", "repositories: [", nil, true},
+		{"wrong shape", "repositories:\n  - a\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importVcstool(context.Background(), "ros2.repos", []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importVcstool() error = %v, want error %v", err, tt.wantErr)
+			}
+			// The repositories of a .repos file are a map, in no order.
+			sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importVcstool() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportRepoManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []importedRepository
+		wantErr bool
+	}{
+		{
+			"projects",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote name="aosp" fetch="https://android.googlesource.com/" revision="refs/heads/main" />
+  <remote name="acme" fetch="https://git.example.com" />
+  <default remote="aosp" revision="refs/tags/android-14.0.0_r1" />
+  <project name="platform/build" path="build/make" groups="pdk,Tools,name:platform/build,path:build/make" />
+  <project name="platform/art" revision="4b825dc642cb6eb9a060e54bf8d69288fbee4904" groups="default notdefault" />
+  <project name="device/acme" remote="acme" revision="develop" />
+  <project name="platform/old" />
+  <remove-project name="platform/old" />
+  <project name="vendor/unknown" remote="nowhere" />
+</manifest>
+`,
+			[]importedRepository{
+				{path: "build/make", url: "https://android.googlesource.com/platform/build", branch: "main", groups: []string{"pdk", "tools"}},
+				{path: "platform/art", url: "https://android.googlesource.com/platform/art"},
+				{path: "device/acme", url: "https://git.example.com/device/acme", branch: "develop"},
+			},
+			false,
+		},
+		{
+			"default revision",
+			`<manifest><remote name="r" fetch="ssh://git.example.com/" /><default remote="r" revision="stable" /><project name="a" /></manifest>`,
+			[]importedRepository{{path: "a", url: "ssh://git.example.com/a", branch: "stable"}},
+			false,
+		},
+		{"not xml", "<manifest>", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importRepoManifest(context.Background(), "default.xml", []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importRepoManifest() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importRepoManifest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// reportFormat is set by --report, a report of the run written to
+	// --report-file once it is over, alongside whatever output is shown.
+	// A markdown report can go to stdout instead, in place of the results.
+	reportFormat   string
+	reportFileName string
+)
+
+// initReport checks --report and --report-file.
+func initReport() error {
+	switch reportFormat {
+	case "":
+		if reportFileName != "" {
+			return errors.New("--report-file needs --report junit or markdown")
+		}
+	case "junit":
+		if reportFileName == "" {
+			return errors.New("--report junit needs --report-file")
+		}
+	case "markdown":
+		if reportFileName != "" {
+			return nil
+		}
+		if machineOutput() {
+			return errors.New("--report markdown without --report-file can't be used with --json, --porcelain, --output or --format")
+		}
+		markdownOutput = true
+	default:
+		return errors.Errorf("unknown report [%s], expected junit or markdown", reportFormat)
+	}
+	return nil
+}
+
+// junitSuites is the root of a JUnit XML report.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+// junitSuite is the run, one testsuite with a testcase per repository.
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+// junitCase is the result of the operation in one repository.
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitMessage is a failure or skip, with the text of a failure's output.
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeReport writes the --report of the run summarized by s.
+func writeReport(s summary) {
+
+	if reportFormat == "" || reportFileName == "" {
+		return
+	}
+
+	f, err := os.Create(reportFileName)
+	if err == nil {
+		if reportFormat == "markdown" {
+			writeMarkdownReport(f, s)
+		} else {
+			err = writeJUnit(f, s)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("unable to write report [%s]: %s", reportFileName, err))
+	}
+}
+
+// writeJUnit writes the results of the run summarized by s to w as JUnit
+// XML: a testcase per repository, named as got shows it, that failed, was
+// skipped or passed.
+func writeJUnit(w io.Writer, s summary) error {
+
+	seconds := func(d float64) string { return strconv.FormatFloat(d, 'f', 3, 64) }
+
+	suite := junitSuite{
+		Name:      "got " + s.Operation,
+		Tests:     s.Total,
+		Failures:  s.Failed,
+		Skipped:   s.Skipped,
+		Time:      seconds(s.Duration),
+		Timestamp: time.Now().Add(-time.Duration(s.Duration * float64(time.Second))).Format("2006-01-02T15:04:05"),
+	}
+
+	resultsMu.Lock()
+	for _, r := range results {
+		c := junitCase{Name: displayName(r.Path), Classname: "got." + r.Operation, Time: seconds(r.Duration), SystemOut: r.Output}
+		switch r.Status {
+		case statusFailed:
+			c.Failure = &junitMessage{Message: r.Error, Text: r.Output}
+			c.SystemOut = ""
+		case statusSkipped:
+			c.Skipped = &junitMessage{Message: r.Detail}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	resultsMu.Unlock()
+
+	report := junitSuites{Name: "got", Tests: s.Total, Failures: s.Failed, Skipped: s.Skipped, Time: suite.Time, Suites: []junitSuite{suite}}
+
+	fmt.Fprint(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,177 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listGroupBy string
+	listOrg     string
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list directory",
+	Short: "List the repositories beneath a directory",
+	Long: `List finds every repository beneath a directory and shows it with its
+current branch and origin remote.
+
+The organization of a repository is read from its remote URL: the path
+between the host and the repository name, such as acme for
+git@github.com:acme/api.git or platform/backend for a GitLab subgroup.
+--group-by org lists repositories under a heading per organization, and
+--org only lists those of one organization, given as acme or
+github.com/acme.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if listGroupBy != "" && listGroupBy != "org" {
+			return usageErrorf("unknown --group-by [%s], expected org", listGroupBy)
+		}
+		return list(ctx, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+	describe(listCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"List the repositories beneath ~/src by organization", "got list --group-by org ~/src"},
+			{"List only the repositories of the acme organization", "got list --org acme ~/src"},
+		},
+	})
+
+	listCmd.Flags().StringVar(&listGroupBy, "group-by", "", "Group the repositories: org")
+	listCmd.Flags().StringVar(&listOrg, "org", "", "Only list the repositories of this organization")
+}
+
+// listedRepository is a repository as list shows it.
+type listedRepository struct {
+	path   string
+	branch string
+	remote string
+	host   string
+	org    string
+}
+
+func list(ctx context.Context, root string) error {
+
+	var repos []listedRepository
+	err := walkRepositories(ctx, root, func(path string) error {
+		r := listedRepository{path: path}
+		r.branch, _ = git.Head(path)
+		if remote, err := remoteURL(ctx, path); err == nil {
+			r.remote = remote
+			r.host, r.org = remoteOrg(remote)
+		}
+		if listOrg == "" || matchOrg(r, listOrg) {
+			repos = append(repos, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(repos) == 0 {
+		fmt.Println("No repositories found")
+		return nil
+	}
+
+	header := []string{"REPOSITORY", "BRANCH", "REMOTE"}
+	row := func(r listedRepository) []string { return []string{displayName(r.path), r.branch, r.remote} }
+	style := func(col int, s string) string {
+		if col == 0 {
+			return styles.Path(s)
+		}
+		return s
+	}
+
+	if listGroupBy == "" {
+		rows := make([][]string, len(repos))
+		for i, r := range repos {
+			rows[i] = row(r)
+		}
+		writeTable(os.Stdout, header, rows, terminalWidth(), func(_, col int, s string) string { return style(col, s) })
+		return nil
+	}
+
+	const noOrg = "(no organization)"
+	grouped := map[string][]listedRepository{}
+	var all [][]string
+	for _, r := range repos {
+		org := noOrg
+		if r.org != "" {
+			org = r.host + "/" + r.org
+		}
+		grouped[org] = append(grouped[org], r)
+		all = append(all, row(r))
+	}
+	orgs := make([]string, 0, len(grouped))
+	for org := range grouped {
+		orgs = append(orgs, org)
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if (orgs[i] == noOrg) != (orgs[j] == noOrg) {
+			return orgs[j] == noOrg
+		}
+		return orgs[i] < orgs[j]
+	})
+
+	widths := columnWidths(header, all, terminalWidth())
+	writeRow(os.Stdout, header, widths, nil)
+	for i, org := range orgs {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(styles.Bold(fmt.Sprintf("%s (%d)", org, len(grouped[org]))))
+		for _, r := range grouped[org] {
+			writeRow(os.Stdout, row(r), widths, style)
+		}
+	}
+	return nil
+}
+
+// remoteOrg returns the host and organization of a remote URL: the path
+// between the host and the repository name. The organization is empty for
+// a URL with no such path, such as a local one.
+func remoteOrg(remote string) (string, string) {
+	parts := strings.Split(normalizeRemoteURL(remote), "/")
+	if len(parts) < 3 || parts[0] == "" {
+		return "", ""
+	}
+	return parts[0], strings.Join(parts[1:len(parts)-1], "/")
+}
+
+// matchOrg reports whether r belongs to org, given with or without the
+// host.
+func matchOrg(r listedRepository, org string) bool {
+	org = strings.ToLower(strings.Trim(org, "/"))
+	return r.org != "" && (org == r.org || org == r.host+"/"+r.org)
+}
@@ -0,0 +1,137 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockFileName is the lock got holds in a repository's git directory while
+// it runs an operation there, alongside git's own index.lock and friends.
+const lockFileName = "got.lock"
+
+const lockPollInterval = 250 * time.Millisecond
+
+// lockRepo takes the got lock of the repository at path, waiting for as
+// long as another got process holds it. waiting is called with a
+// description of the holder when the wait starts. A lock left behind by a
+// got process that has since died is taken over. The returned function
+// releases the lock, if it is still ours.
+func lockRepo(ctx context.Context, path string, waiting func(holder string)) (func(), error) {
+
+	lock := filepath.Join(gitDir(path), lockFileName)
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%d %s\n", os.Getpid(), host)
+
+	announced := false
+	for {
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.WriteString(owner)
+			f.Close()
+			return func() {
+				if pid, holderHost := lockHolder(lock); pid == os.Getpid() && holderHost == host {
+					os.Remove(lock)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrapf(err, "unable to lock [%s]", path)
+		}
+
+		pid, holderHost := lockHolder(lock)
+		if pid > 0 && holderHost == host && !processAlive(pid) {
+			takeOverLock(lock, pid, holderHost)
+			continue
+		}
+
+		if !announced {
+			holder := "another got process"
+			if pid > 0 {
+				holder = fmt.Sprintf("got (pid %d)", pid)
+			}
+			waiting(holder)
+			announced = true
+		}
+
+		select {
+		case <-time.After(lockPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// takeOverLock removes lock, left behind by the dead process pid on host.
+// Another got may be taking it over at the same time, and may already hold
+// it anew, so rather than removing the lock outright it is renamed out of
+// the way, which only one process can do, and removed only if it is still
+// the dead process's. Otherwise it is put back.
+func takeOverLock(lock string, pid int, host string) {
+	stale := fmt.Sprintf("%s.%d", lock, os.Getpid())
+	if err := os.Rename(lock, stale); err != nil {
+		return
+	}
+	if p, h := lockHolder(stale); p != pid || h != host {
+		// Link rather than rename back, so as not to replace a lock
+		// taken since.
+		os.Link(stale, lock)
+	}
+	os.Remove(stale)
+}
+
+// lockHolder reads the pid and host recorded in lock. The pid is zero if
+// the lock can't be read, for instance while it is still being written.
+func lockHolder(lock string) (int, string) {
+	data, err := ioutil.ReadFile(lock)
+	if err != nil {
+		return 0, ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, ""
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, ""
+	}
+	return pid, fields[1]
+}
+
+// processAlive reports whether a process with pid is running on this
+// machine.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess only succeeds for running processes on Windows.
+		return true
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,102 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// logFile is the file set by --log-file or the logFile config key. It gets
+// an unstyled copy of everything got logs, plus the git output of every
+// repository, whatever is shown on screen.
+var (
+	logFileMu sync.Mutex
+	logFile   *os.File
+)
+
+var ansiSequence = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]")
+
+// openLogFile opens the log file, if one is configured, for appending.
+func openLogFile(args []string) error {
+
+	name := viper.GetString("logFile")
+	if name == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open log file [%s]", name)
+	}
+	logFile = f
+	setLogOutput(os.Stderr)
+	writeLogFile("got %s", strings.Join(args, " "))
+	return nil
+}
+
+// closeLogFile records err, if any, and closes the log file.
+func closeLogFile(err error) {
+	if logFile == nil {
+		return
+	}
+	if err != nil {
+		writeLogFile("error: %v", err)
+	}
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+	logFile.Close()
+	logFile = nil
+}
+
+// writeLogFile writes a timestamped, unstyled line to the log file only.
+func writeLogFile(format string, args ...interface{}) {
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+	if logFile == nil {
+		return
+	}
+	log.New(logFile, "", log.LstdFlags).Print(ansiSequence.ReplaceAllString(fmt.Sprintf(format, args...), ""))
+}
+
+// logRepoOutput writes the git output of the repository at path to the log
+// file, each line marked with the repository.
+func logRepoOutput(path, output string) {
+	if logFile == nil || output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		writeLogFile("  [%s] | %s", path, ansiSequence.ReplaceAllString(line, ""))
+	}
+}
+
+// unstyledWriter writes to the log file with escape sequences removed.
+type unstyledWriter struct{}
+
+func (unstyledWriter) Write(p []byte) (int, error) {
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+	if logFile != nil {
+		logFile.Write(ansiSequence.ReplaceAll(p, nil))
+	}
+	return len(p), nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	logLevelName string
+	logFormat    string
+
+	logLevel = new(slog.LevelVar)
+
+	// logger is what got logs through: result lines, warnings and, at debug
+	// level, the git commands it runs. By default it writes the styled lines
+	// of consoleHandler.
+	logger = slog.New(consoleHandler{})
+)
+
+var (
+	logOutputMu sync.Mutex
+	logOutput   io.Writer = os.Stderr
+)
+
+// initLogger sets the logger up from --log-level and --log-format. -v
+// lowers the level to debug unless --log-level says otherwise.
+func initLogger(levelSet bool) error {
+
+	level := slog.LevelInfo
+	if levelSet {
+		if err := level.UnmarshalText([]byte(logLevelName)); err != nil {
+			return errors.Errorf("unknown log level [%s], expected debug, info, warn or error", logLevelName)
+		}
+	} else if verbosity > 0 {
+		level = slog.LevelDebug
+	}
+	logLevel.Set(level)
+
+	switch logFormat {
+	case "", "text":
+		logger = slog.New(logFileHandler{consoleHandler{}})
+	case "json":
+		logger = slog.New(logFileHandler{unstyledHandler{slog.NewJSONHandler(logSink{}, &slog.HandlerOptions{Level: logLevel})}})
+	default:
+		return errors.Errorf("unknown log format [%s], expected text or json", logFormat)
+	}
+	return nil
+}
+
+// setLogOutput sends the log to w, and to the log file as well.
+func setLogOutput(w io.Writer) {
+	if logFile != nil {
+		w = io.MultiWriter(w, unstyledWriter{})
+	}
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	logOutput = w
+}
+
+// logSink writes to wherever the log is currently going.
+type logSink struct{}
+
+func (logSink) Write(p []byte) (int, error) {
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	return logOutput.Write(p)
+}
+
+// consoleHandler writes each record as a timestamped line holding just its
+// message, which carries got's styling; attributes are for the other
+// handlers.
+type consoleHandler struct{}
+
+func (consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	line := r.Time.Format("2006/01/02 15:04:05") + " " + strings.TrimRight(r.Message, "\n") + "\n"
+	_, err := logSink{}.Write([]byte(line))
+	return err
+}
+
+func (h consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h consoleHandler) WithGroup(string) slog.Handler      { return h }
+
+// unstyledHandler strips the escape sequences of got's styling from
+// messages before handing them on.
+type unstyledHandler struct {
+	slog.Handler
+}
+
+func (h unstyledHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Message = strings.TrimSpace(ansiSequence.ReplaceAllString(r.Message, ""))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h unstyledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return unstyledHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h unstyledHandler) WithGroup(name string) slog.Handler {
+	return unstyledHandler{h.Handler.WithGroup(name)}
+}
+
+// logFileHandler hands records on to a handler for the screen, and copies
+// those below the log level straight to the log file, which gets all of
+// them.
+type logFileHandler struct {
+	slog.Handler
+}
+
+func (h logFileHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return logFile != nil || h.Handler.Enabled(ctx, level)
+}
+
+func (h logFileHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.Handler.Enabled(ctx, r.Level) {
+		writeLogFile("%s", r.Message)
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logFileHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logFileHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logFileHandler) WithGroup(name string) slog.Handler {
+	return logFileHandler{h.Handler.WithGroup(name)}
+}
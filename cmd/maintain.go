@@ -0,0 +1,123 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maintainCmd represents the maintain command
+var maintainCmd = &cobra.Command{
+	Use:   "maintain profile directory",
+	Short: "Run a maintenance profile from the config file",
+	Long: `Maintain runs the git commands of a maintenance profile in a repository, or
+with -r in every repository beneath a directory. Profiles are defined in the
+config file as lists of git commands:
+
+  maintenance:
+    nightly:
+      - fetch --prune
+      - gc --auto
+    weekly:
+      - fsck
+      - lfs prune
+
+The commands of a profile run in order and stop at the first one that fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 1 {
+			return usageErrorf("profile argument is required")
+		}
+		steps, err := maintenanceProfile(args[0])
+		if err != nil {
+			return err
+		}
+		dirs, err := directoryArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		op := func(ctx context.Context, path string) error { return maintain(ctx, path, steps) }
+		return runOperation(ctx, dirs[0], op)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(maintainCmd)
+	describe(maintainCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Run the nightly profile in every repository beneath ~/src", "got maintain nightly -r ~/src"},
+		},
+	})
+
+	maintainCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively maintain subdirectories listed")
+}
+
+// maintenanceProfile returns the git commands of the named profile, each
+// split into its arguments.
+func maintenanceProfile(name string) ([][]string, error) {
+
+	profiles := viper.GetStringMapStringSlice("maintenance")
+	commands, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, usageErrorf("unknown maintenance profile [%s], none are configured", name)
+		}
+		return nil, usageErrorf("unknown maintenance profile [%s], expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	var steps [][]string
+	for _, command := range commands {
+		args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(command), "git "))
+		if len(args) > 0 {
+			steps = append(steps, args)
+		}
+	}
+	return steps, nil
+}
+
+func maintain(ctx context.Context, path string, steps [][]string) error {
+
+	if !isRepository(path) {
+		if recursive {
+			return nil
+		}
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	for _, args := range steps {
+		if err := runner.Run(ctx, path, repoOutput(path), args...); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			repoFailed(path, errors.Wrapf(err, "git %s", strings.Join(args, " ")))
+			return nil
+		}
+	}
+
+	repoSucceeded(path, "Success")
+	return nil
+}
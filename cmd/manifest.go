@@ -0,0 +1,220 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// workspaceManifest describes the repositories a workspace should have. It
+// is read from YAML, or any other format viper supports:
+//
+//	repositories:
+//	  - path: services/api
+//	    remote: git@github.com:example/api.git
+//	    branch: main
+//
+// Paths are relative to the workspace directory; branch is optional.
+type workspaceManifest struct {
+	Repositories []workspaceRepo `mapstructure:"repositories"`
+}
+
+// workspaceRepo is a repository listed in a workspaceManifest.
+type workspaceRepo struct {
+	Path   string `mapstructure:"path" yaml:"path" json:"path"`
+	Remote string `mapstructure:"remote" yaml:"remote,omitempty" json:"remote,omitempty"`
+	Branch string `mapstructure:"branch" yaml:"branch,omitempty" json:"branch,omitempty"`
+}
+
+// loadManifest reads the manifest in name.
+func loadManifest(name string) (*workspaceManifest, error) {
+
+	v := viper.New()
+	v.SetConfigFile(name)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.Wrapf(err, "unable to read manifest [%s]", name)
+	}
+
+	var m workspaceManifest
+	if err := v.Unmarshal(&m); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest [%s]", name)
+	}
+
+	seen := map[string]bool{}
+	for i, r := range m.Repositories {
+		if r.Path == "" {
+			return nil, errors.Errorf("repository %d of manifest [%s] has no path", i+1, name)
+		}
+		path := filepath.Clean(filepath.FromSlash(r.Path))
+		if seen[path] {
+			return nil, errors.Errorf("manifest [%s] lists [%s] more than once", name, r.Path)
+		}
+		seen[path] = true
+		m.Repositories[i].Path = path
+	}
+
+	return &m, nil
+}
+
+// configManifest returns the repositories of the manifest section of the
+// config file, which lists where each repository of the workspace belongs,
+// its remote and its default branch:
+//
+//	manifest:
+//	  - path: ~/src/acme/api
+//	    remote: git@github.com:acme/api.git
+//	    branch: main
+//
+// It is a list rather than a mapping of paths because config keys are read
+// without their case. Paths are returned absolute.
+func configManifest() ([]workspaceRepo, error) {
+
+	var repos []workspaceRepo
+	if err := viper.UnmarshalKey("manifest", &repos); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the manifest in the config file")
+	}
+	if len(repos) == 0 {
+		return nil, errors.New("the config file has no manifest")
+	}
+
+	seen := map[string]bool{}
+	for i, r := range repos {
+		if r.Path == "" {
+			return nil, errors.Errorf("repository %d of the manifest in the config file has no path", i+1)
+		}
+		path := absPath(expandHome(r.Path))
+		if seen[path] {
+			return nil, errors.Errorf("the manifest in the config file lists [%s] more than once", r.Path)
+		}
+		seen[path] = true
+		repos[i].Path = path
+	}
+	return repos, nil
+}
+
+// writeConfigManifest writes repos as the manifest section of a config
+// file, and groups, by name, as its groups section, under a comment saying
+// where they came from.
+func writeConfigManifest(w io.Writer, comment string, repos []workspaceRepo, groups map[string][]string) error {
+
+	section := struct {
+		Manifest []workspaceRepo     `yaml:"manifest"`
+		Groups   map[string][]string `yaml:"groups,omitempty"`
+	}{repos, groups}
+
+	fmt.Fprintf(w, "# %s\n", comment)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(section); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// runManifest runs op for every repository of the config manifest as a
+// recursive run would, whether the repository is there yet or not.
+func runManifest(ctx context.Context, op func(ctx context.Context, r workspaceRepo) error) error {
+
+	repos, err := configManifest()
+	if err != nil {
+		return err
+	}
+	byPath := map[string]workspaceRepo{}
+	for _, r := range repos {
+		byPath[r.Path] = r
+	}
+
+	recursive = true
+	started := time.Now()
+	err = walkFound(ctx, func(fn func(path string) error) error {
+		for _, r := range repos {
+			if err := fn(r.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, path string) error {
+		return op(ctx, byPath[path])
+	})
+	saveHostLimits()
+
+	finishRun(time.Since(started))
+	return err
+}
+
+// manifestDrift describes how the repository at r.Path differs from r: a
+// different origin remote, or no local or remote-tracking branch by the
+// name of its default branch. It is empty for a missing repository, which
+// callers deal with themselves.
+func manifestDrift(ctx context.Context, r workspaceRepo) []string {
+
+	var problems []string
+	if r.Remote != "" {
+		url, _ := remoteURL(ctx, r.Path)
+		if normalizeRemoteURL(url) != normalizeRemoteURL(r.Remote) {
+			if url == "" {
+				url = "none"
+			}
+			problems = append(problems, fmt.Sprintf("remote is %s, manifest has %s", url, r.Remote))
+		}
+	}
+	if r.Branch != "" && !hasBranch(ctx, r.Path, r.Branch) {
+		problems = append(problems, fmt.Sprintf("no %s branch", r.Branch))
+	}
+	return problems
+}
+
+// hasBranch reports whether the repository at path has branch, locally or
+// as a remote-tracking branch of origin.
+func hasBranch(ctx context.Context, path, branch string) bool {
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		if _, err := gitOutput(ctx, path, "rev-parse", "--verify", "--quiet", ref); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneManifestRepo clones r to its path, checking out its default branch.
+func cloneManifestRepo(ctx context.Context, r workspaceRepo) error {
+
+	if r.Remote == "" {
+		return errors.New("missing, and the manifest has no remote to clone")
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return errors.Wrapf(err, "unable to create [%s]", filepath.Dir(r.Path))
+	}
+
+	args := []string{"clone"}
+	if r.Branch != "" {
+		args = append(args, "--branch", r.Branch)
+	}
+	args = append(args, r.Remote, r.Path)
+
+	// There is no repository to point git at yet.
+	cmd := gitExec(ctx, r.Path, args...)
+	cmd.Stdout = repoCapture(r.Path)
+	cmd.Stderr = repoCapture(r.Path)
+	return cmd.Run()
+}
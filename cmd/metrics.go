@@ -0,0 +1,179 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	fetchedMu sync.Mutex
+	// fetched is how many bytes the object store of each repository grew
+	// by during the run, measured only for a --metrics-file.
+	fetched = map[string]int64{}
+)
+
+// measureFetched runs fn in the repository at path and, when a metrics
+// file is being written and fn is a fetch or pull, records how much its
+// object store grew by as what was fetched.
+func measureFetched(ctx context.Context, path string, fn func() error) error {
+
+	if viper.GetString("metricsFile") == "" || (operation != "fetch" && operation != "pull") {
+		return fn()
+	}
+
+	before := objectsSize(ctx, path)
+	err := fn()
+	if grown := objectsSize(ctx, path) - before; grown > 0 {
+		fetchedMu.Lock()
+		fetched[path] = grown
+		fetchedMu.Unlock()
+	}
+	return err
+}
+
+// objectsSize returns the size of the object store of the repository at
+// path in bytes, loose objects and packs together.
+func objectsSize(ctx context.Context, path string) int64 {
+	out, err := gitOutput(ctx, path, "count-objects", "-v")
+	if err != nil {
+		return 0
+	}
+	var kib int64
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && (fields[0] == "size:" || fields[0] == "size-pack:") {
+			n, _ := strconv.ParseInt(fields[1], 10, 64)
+			kib += n
+		}
+	}
+	return kib * 1024
+}
+
+// writeMetrics writes the statistics of the run summarized by s to the
+// metricsFile in the config file, or given with --metrics-file, in the
+// Prometheus text format, for node_exporter's textfile collector to pick
+// up. The file is replaced as a whole so the collector never reads half of
+// it; give each scheduled run a file of its own, such as
+// /var/lib/node_exporter/got-pull.prom.
+func writeMetrics(s summary) {
+
+	name := viper.GetString("metricsFile")
+	if name == "" {
+		return
+	}
+	name = expandHome(name)
+
+	var b strings.Builder
+	op := `operation="` + metricLabel(s.Operation) + `"`
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("got_run_repositories", "gauge", "Repositories the run processed, by outcome.")
+	for _, c := range []struct {
+		status string
+		n      int
+	}{{statusSuccess, s.Succeeded}, {statusSkipped, s.Skipped}, {statusFailed, s.Failed}} {
+		fmt.Fprintf(&b, "got_run_repositories{%s,status=%q} %d\n", op, c.status, c.n)
+	}
+	metric("got_run_failures", "gauge", "Repositories that failed in the run.")
+	fmt.Fprintf(&b, "got_run_failures{%s} %d\n", op, s.Failed)
+	metric("got_run_duration_seconds", "gauge", "How long the run took.")
+	fmt.Fprintf(&b, "got_run_duration_seconds{%s} %s\n", op, metricFloat(s.Duration))
+	metric("got_run_timestamp_seconds", "gauge", "When the run finished, as a Unix time.")
+	fmt.Fprintf(&b, "got_run_timestamp_seconds{%s} %d\n", op, time.Now().Unix())
+
+	resultsMu.Lock()
+	repos := append([]result(nil), results...)
+	resultsMu.Unlock()
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Path < repos[j].Path })
+
+	fetchedMu.Lock()
+	var total int64
+	for _, n := range fetched {
+		total += n
+	}
+	metric("got_run_fetched_bytes", "gauge", "How much the object stores of the repositories grew by in a fetch or pull.")
+	fmt.Fprintf(&b, "got_run_fetched_bytes{%s} %d\n", op, total)
+
+	metric("got_repository_duration_seconds", "gauge", "How long the operation took in each repository.")
+	for _, r := range repos {
+		fmt.Fprintf(&b, "got_repository_duration_seconds{%s,repository=\"%s\"} %s\n", op, metricLabel(r.Path), metricFloat(r.Duration))
+	}
+	metric("got_repository_failed", "gauge", "Whether the operation failed in each repository.")
+	for _, r := range repos {
+		failed := 0
+		if r.Status == statusFailed {
+			failed = 1
+		}
+		fmt.Fprintf(&b, "got_repository_failed{%s,repository=\"%s\"} %d\n", op, metricLabel(r.Path), failed)
+	}
+	metric("got_repository_fetched_bytes", "gauge", "How much the object store of each repository grew by in a fetch or pull.")
+	for _, r := range repos {
+		fmt.Fprintf(&b, "got_repository_fetched_bytes{%s,repository=\"%s\"} %d\n", op, metricLabel(r.Path), fetched[r.Path])
+	}
+	fetchedMu.Unlock()
+
+	if err := replaceFile(name, []byte(b.String())); err != nil {
+		logger.Error(fmt.Sprintf("unable to write metrics file [%s]: %s", name, err))
+	}
+}
+
+// replaceFile writes data to a file beside name and renames it into place.
+func replaceFile(name string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	os.Chmod(f.Name(), 0644)
+	if err := os.Rename(f.Name(), name); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// metricLabel escapes a label value for the Prometheus text format.
+func metricLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// metricFloat formats a sample value.
+func metricFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,121 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// repoNames returns the short names given to repositories in the config
+// file, mapped to their absolute paths:
+//
+//	names:
+//	  api: ~/src/company/backend/services/api-service
+//	  web: ~/src/company/frontend/web
+func repoNames() map[string]string {
+	names := map[string]string{}
+	for name, path := range viper.GetStringMapString("names") {
+		names[name] = absPath(expandHome(path))
+	}
+	return names
+}
+
+// repoArg returns the path of the repository named arg in the config file,
+// unless arg is a path that exists, in which case it is returned as is.
+func repoArg(arg string) string {
+	if _, err := os.Stat(arg); err == nil {
+		return arg
+	}
+	if path, ok := repoNames()[strings.ToLower(arg)]; ok {
+		return path
+	}
+	return arg
+}
+
+// scanRoot is the directory a recursive run is walking, which --relative
+// shows paths relative to.
+var scanRoot string
+
+// displayName returns the name path is given in the config file, or else,
+// with --relative, path relative to the directory being walked, or else
+// path itself.
+func displayName(path string) string {
+	abs := absPath(path)
+	for name, p := range repoNames() {
+		if samePath(p, abs) {
+			return name
+		}
+	}
+	if viper.GetBool("relative") && scanRoot != "" {
+		if rel, err := filepath.Rel(absPath(scanRoot), abs); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// expandHome replaces a leading ~ in path with the home directory. On
+// Windows ~\ works as well as ~/.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// abbreviateHome replaces the home directory at the start of path with ~,
+// the reverse of expandHome.
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// foldPath returns path in the case it is compared in: as it is, or on
+// Windows, whose file systems ignore case, in lower case.
+func foldPath(path string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// samePath reports whether a and b are the same path, C:\Src and c:\src
+// included on Windows.
+func samePath(a, b string) bool {
+	return foldPath(filepath.Clean(a)) == foldPath(filepath.Clean(b))
+}
@@ -0,0 +1,256 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// outputFormat is set by --output; --json and --porcelain are
+	// shorthands for two of its values.
+	outputFormat string
+	csvOutput    bool
+
+	// markdownOutput is set by --report markdown without --report-file,
+	// which writes the report to stdout once the run is over in place of
+	// the results.
+	markdownOutput bool
+
+	// outputFileName is set by --output-file, which sends the results
+	// there rather than to stdout.
+	outputFileName string
+	outputFile     *os.File
+
+	// formatTemplate is set by --format, a Go template executed for every
+	// repository in place of the usual result line.
+	formatTemplate string
+	lineTemplate   *template.Template
+)
+
+// initOutput settles the output format from --output, --json and --porcelain
+// and opens --output-file.
+func initOutput() error {
+
+	switch outputFormat {
+	case "", "text":
+	case "json":
+		jsonOutput = true
+	case "porcelain":
+		porcelainOutput = true
+	case "csv":
+		csvOutput = true
+	default:
+		return errors.Errorf("unknown output format [%s], expected text, json, porcelain or csv", outputFormat)
+	}
+
+	if formatTemplate != "" {
+		t, err := template.New("format").Funcs(templateFuncs).Parse(formatTemplate)
+		if err != nil {
+			return errors.Wrap(err, "invalid --format template")
+		}
+		lineTemplate = t
+	}
+
+	formats := 0
+	for _, set := range []bool{jsonOutput, porcelainOutput, csvOutput, lineTemplate != nil} {
+		if set {
+			formats++
+		}
+	}
+	if formats > 1 {
+		return errors.New("only one of --json, --porcelain, --output and --format can be used")
+	}
+
+	if outputFileName == "" {
+		return nil
+	}
+	if !machineOutput() {
+		return errors.New("--output-file needs --output json, porcelain or csv, or --format")
+	}
+	f, err := os.Create(outputFileName)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create output file [%s]", outputFileName)
+	}
+	outputFile = f
+	return nil
+}
+
+// closeOutput closes --output-file.
+func closeOutput() {
+	if outputFile != nil {
+		outputFile.Close()
+	}
+}
+
+// machineWriter returns where results for a program should be written.
+func machineWriter() io.Writer {
+	if outputFile != nil {
+		return outputFile
+	}
+	return consoleOutput()
+}
+
+var (
+	csvHeader sync.Once
+	csvWriter *csv.Writer
+)
+
+// writeCSV writes r as a CSV record, after a header naming the columns.
+// With --jobs results come in from several goroutines, and the writer is
+// shared, so records are written holding resultsMu.
+func writeCSV(r result) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	csvHeader.Do(func() {
+		csvWriter = csv.NewWriter(machineWriter())
+		csvWriter.Write([]string{"path", "branch", "operation", "status", "detail", "error", "duration"})
+	})
+	csvWriter.Write([]string{r.Path, r.branch, r.Operation, r.Status, r.Detail, r.Error, strconv.FormatFloat(r.Duration, 'f', 3, 64)})
+	csvWriter.Flush()
+}
+
+// templateResult is what a --format template is executed with, one per
+// repository:
+//
+//	.Path       path of the repository
+//	.Name       the repository as got shows it: its configured name, its
+//	            path relative to the walk with --relative, or its path
+//	.Branch     current branch, empty when HEAD is detached
+//	.Operation  the command that ran, such as pull
+//	.Status     success, skipped or failed
+//	.Detail     what happened, such as "Up to date"
+//	.Error      why it failed
+//	.Output     everything git wrote
+//	.Duration   how long it took, such as 1.2s
+//
+// The functions json, upper, lower, join and trim are available besides the
+// template builtins.
+type templateResult struct {
+	Path      string
+	Name      string
+	Branch    string
+	Operation string
+	Status    string
+	Detail    string
+	Error     string
+	Output    string
+	Duration  time.Duration
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"trim":  strings.TrimSpace,
+}
+
+var templateMu sync.Mutex
+
+// writeTemplate writes r through the --format template, on a line of its
+// own.
+func writeTemplate(r result) {
+
+	var b strings.Builder
+	err := lineTemplate.Execute(&b, templateResult{
+		Path:      r.Path,
+		Name:      displayName(r.Path),
+		Branch:    r.branch,
+		Operation: r.Operation,
+		Status:    r.Status,
+		Detail:    r.Detail,
+		Error:     r.Error,
+		Output:    r.Output,
+		Duration:  time.Duration(r.Duration * float64(time.Second)).Round(time.Millisecond),
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("[%s] unable to execute --format template: %s", displayName(r.Path), err), "path", r.Path)
+		return
+	}
+	line := b.String()
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+
+	templateMu.Lock()
+	defer templateMu.Unlock()
+	io.WriteString(machineWriter(), line)
+}
+
+// writeMarkdownReport writes the results of the run as GitHub-flavored
+// Markdown: a summary line and table, then a section for every repository
+// that failed or had output.
+func writeMarkdownReport(w io.Writer, s summary) {
+
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	cell := strings.NewReplacer("|", "\\|", "\n", " ", "\r", "")
+
+	fmt.Fprintf(w, "## got %s\n\n", s.Operation)
+	fmt.Fprintf(w, "%d repositories: %d succeeded, %d skipped, %d failed in %s.\n\n",
+		s.Total, s.Succeeded, s.Skipped, s.Failed, time.Duration(s.Duration*float64(time.Second)).Round(time.Millisecond))
+
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "| Repository | Branch | Result | Duration |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	for _, r := range results {
+		outcome := iconSuccess + " " + r.Detail
+		switch r.Status {
+		case statusSkipped:
+			outcome = iconSkipped + " " + r.Detail
+		case statusFailed:
+			outcome = iconError + " " + r.Error
+		}
+		duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Millisecond)
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", cell.Replace(displayName(r.Path)), cell.Replace(r.branch), cell.Replace(outcome), duration)
+	}
+
+	for _, r := range results {
+		if r.Status != statusFailed && strings.TrimSpace(r.Output) == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\n### `%s`\n\n", displayName(r.Path))
+		if r.Error != "" {
+			fmt.Fprintf(w, "**Error:** %s\n\n", r.Error)
+		}
+		if output := strings.TrimRight(r.Output, "\n"); output != "" {
+			fence := "```"
+			if strings.Contains(output, fence) {
+				fence = "~~~~"
+			}
+			fmt.Fprintf(w, "%s\n%s\n%s\n", fence, output, fence)
+		}
+	}
+}
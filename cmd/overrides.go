@@ -0,0 +1,188 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/spf13/viper"
+)
+
+// override is a set of settings for the repositories beneath a path, read
+// from the overrides section of the config file:
+//
+//	overrides:
+//	  - path: ~/src/legacy
+//	    pull: false
+//	    fetch: false
+//	  - path: ~/src/infra
+//	    branch: main
+//	    pull: ff-only
+//	  - path: ~/src/acme/*
+//	    pull: rebase
+//
+// A command set to false is skipped in those repositories, and with a
+// branch, commands that change repositories only run in them while they
+// are on that branch. pull can also name a strategy, rebase, ff-only or
+// merge, that got pull passes on to git pull. Paths can be globs, matching
+// the repositories beneath what they match, and when overrides overlap the
+// longest path wins, a path over a glob as long as it. Overrides are a
+// list, as the manifest is, because config keys are read without their
+// case.
+type override struct {
+	Path         string
+	Branch       string
+	Operations   map[string]bool
+	PullStrategy string
+}
+
+// pullStrategies are the git pull flags of the pull strategies an override
+// can name.
+var pullStrategies = map[string]string{
+	"rebase":  "--rebase",
+	"ff-only": "--ff-only",
+	"merge":   "--no-rebase",
+}
+
+var (
+	overridesOnce sync.Once
+	overrideList  []override
+)
+
+// overrides returns the overrides in the config file, read the first time
+// they are needed. Malformed ones are reported and otherwise ignored.
+func overrides() []override {
+	overridesOnce.Do(func() {
+		overrideList = readOverrides()
+	})
+	return overrideList
+}
+
+func readOverrides() []override {
+
+	items, _ := viper.Get("overrides").([]interface{})
+
+	var list []override
+	for i, item := range items {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			logger.Warn(fmt.Sprintf("ignoring override %d in config file: not a set of settings", i+1))
+			continue
+		}
+		o := override{Operations: map[string]bool{}}
+		for key, v := range settings {
+			switch key = strings.ToLower(key); key {
+			case "path":
+				o.Path = filepath.Clean(expandHome(fmt.Sprint(v)))
+				if !strings.ContainsAny(o.Path, "*?[") {
+					o.Path = absPath(o.Path)
+				}
+			case "branch":
+				o.Branch = fmt.Sprint(v)
+			case "pull":
+				if strategy, ok := v.(string); ok {
+					if _, known := pullStrategies[strategy]; !known {
+						logger.Warn(fmt.Sprintf("ignoring pull of override %d in config file: expected false, rebase, ff-only or merge", i+1))
+						continue
+					}
+					o.PullStrategy = strategy
+					continue
+				}
+				fallthrough
+			default:
+				enabled, ok := v.(bool)
+				if !ok {
+					logger.Warn(fmt.Sprintf("ignoring %s of override %d in config file: expected true or false", key, i+1))
+					continue
+				}
+				o.Operations[key] = enabled
+			}
+		}
+		if o.Path == "" {
+			logger.Warn(fmt.Sprintf("ignoring override %d in config file: no path", i+1))
+			continue
+		}
+		list = append(list, o)
+	}
+	return list
+}
+
+// overrideOf returns the override for the repository at path.
+func overrideOf(path string) (override, bool) {
+
+	abs := absPath(path)
+
+	var found override
+	longest := -1
+	for _, o := range overrides() {
+		// A path wins over a glob as long as it.
+		n := 2 * len(o.Path)
+		if !strings.ContainsAny(o.Path, "*?[") {
+			n++
+		}
+		if overrideMatches(o, abs) && n > longest {
+			found, longest = o, n
+		}
+	}
+	return found, longest >= 0
+}
+
+// overrideMatches reports whether o covers the repository at abs: whether
+// abs is o's path, or what it matches, or is beneath it.
+func overrideMatches(o override, abs string) bool {
+	if !strings.ContainsAny(o.Path, "*?[") {
+		return within(abs, []string{o.Path})
+	}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if ok, _ := filepath.Match(foldPath(o.Path), foldPath(dir)); ok {
+			return true
+		}
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+// pullArgs returns the arguments of git pull in the repository at path,
+// with the strategy of its override.
+func pullArgs(path string) []string {
+	if o, ok := overrideOf(path); ok && o.PullStrategy != "" {
+		return []string{"pull", pullStrategies[o.PullStrategy]}
+	}
+	return []string{"pull"}
+}
+
+// overridden returns why the override for the repository at path keeps the
+// running command out of it, if it does.
+func overridden(path string) (string, bool) {
+
+	o, ok := overrideOf(path)
+	if !ok {
+		return "", false
+	}
+	if enabled, set := o.Operations[operation]; set && !enabled {
+		return fmt.Sprintf("%s: false in config", operation), true
+	}
+	if info, ok := registry[operation]; ok && info.Mutating && o.Branch != "" {
+		if branch, _ := git.Head(path); branch != o.Branch {
+			return fmt.Sprintf("only on %s", o.Branch), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,105 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGitPathArg(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"src", filepath.ToSlash(filepath.Join(wd, "src"))},
+		{filepath.Join(wd, "a", "..", "b"), filepath.ToSlash(filepath.Join(wd, "b"))},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, []struct {
+			path string
+			want string
+		}{
+			{`C:\src\legacy-app`, "C:/src/legacy-app"},
+			{`C:/src\legacy-app`, "C:/src/legacy-app"},
+			{`\\server\share\repo`, "//server/share/repo"},
+			{`//server/share\repo`, "//server/share/repo"},
+		}...)
+	} else {
+		tests = append(tests, []struct {
+			path string
+			want string
+		}{
+			{"/src/legacy-app", "/src/legacy-app"},
+			{"/src//legacy-app/", "/src/legacy-app"},
+		}...)
+	}
+
+	for _, tt := range tests {
+		if got := gitPathArg(tt.path); got != tt.want {
+			t.Errorf("gitPathArg(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGitPathArgDriveRelative(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("drive-relative paths only exist on Windows")
+	}
+	// C:src is src in the current directory of drive C, not C:\src.
+	got := gitPathArg("C:src")
+	if !strings.HasPrefix(got, "C:/") || !strings.HasSuffix(got, "/src") {
+		t.Errorf("gitPathArg(%q) = %q, want an absolute path on C: ending in /src", "C:src", got)
+	}
+}
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		windows bool
+		want    bool
+	}{
+		{"legacy-*", "/src/legacy-app", false, true},
+		{"/src/legacy-*", "/src/legacy-app", false, true},
+		{"/src/*", "/src/acme/legacy-app", false, false},
+		{"/SRC/legacy-*", "/src/legacy-app", false, false},
+
+		{`C:\src\legacy-*`, `C:\src\legacy-app`, true, true},
+		{`C:\src\legacy-*`, `c:\SRC\Legacy-App`, true, true},
+		{`C:/src/legacy-*`, `C:\src\legacy-app`, true, true},
+		{`C:\src/legacy-*`, `C:\src\legacy-app`, true, true},
+		{`C:\src\*`, `C:\src\acme\legacy-app`, true, false},
+		{`C:\src\legacy-*`, `D:\src\legacy-app`, true, false},
+		{"LEGACY-*", `C:\src\legacy-app`, true, true},
+		{`\\server\share\*`, `\\server\share\repo`, true, true},
+		{`//server/share/*`, `\\server\share\repo`, true, true},
+		{`\\server\share\*`, `\\other\share\repo`, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPathPattern(tt.pattern, tt.dir, tt.windows); got != tt.want {
+			t.Errorf("matchPathPattern(%q, %q, %v) = %v, want %v", tt.pattern, tt.dir, tt.windows, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHost is an SSH server the remotes of a run point at, as ssh itself
+// would connect to it.
+type sshHost struct {
+	hostname   string
+	port       string
+	knownHosts []string
+	strict     string
+}
+
+// knownHostsName is how the host appears in a known_hosts file.
+func (h sshHost) knownHostsName() string {
+	if h.port == "22" {
+		return h.hostname
+	}
+	return "[" + h.hostname + "]:" + h.port
+}
+
+// sshPreflight checks, before a pull or fetch touches any repository, that
+// the host key of every SSH server the origins beneath root point at is in
+// known_hosts and matches the fingerprints pinned for it in the config:
+//
+//	ssh:
+//	  fingerprints:
+//	    github.com:
+//	      - SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU
+//
+// A missing or mismatched key would otherwise make every repository fail
+// with the same host verification error, or stop at the same prompt. Hosts
+// that ssh is configured not to check strictly are left alone. A run with
+// many SSH remotes is also warned about when no ssh-agent is running.
+func sshPreflight(ctx context.Context, root string) error {
+
+	users := map[string][]string{}
+	check := func(p string) error {
+		if url, err := remoteURL(ctx, p); err == nil {
+			if host, port, ok := sshRemote(url); ok {
+				key := net.JoinHostPort(host, port)
+				users[key] = append(users[key], p)
+			}
+		}
+		return nil
+	}
+	if recursive {
+		if err := walkRepositories(ctx, root, check); err != nil {
+			return err
+		}
+	} else if isRepository(root) {
+		check(root)
+	}
+
+	keys := make([]string, 0, len(users))
+	sshRepos := 0
+	for key := range users {
+		keys = append(keys, key)
+		sshRepos += len(users[key])
+	}
+	sort.Strings(keys)
+	warnSSHAgent(sshRepos)
+
+	pins := viper.GetStringMapStringSlice("ssh.fingerprints")
+
+	var problems []string
+	for _, key := range keys {
+		host, port, _ := net.SplitHostPort(key)
+		h := resolveSSHHost(ctx, host, port)
+		switch h.strict {
+		case "no", "false", "off", "accept-new":
+			continue
+		}
+
+		repos := users[key]
+		known := knownHostKeys(h.knownHosts, h.knownHostsName())
+		if len(known) == 0 {
+			problems = append(problems, fmt.Sprintf(
+				"%s is not in known_hosts (used by %d repositories, such as [%s]); check its fingerprint and add it with: ssh-keyscan -p %s %s >> ~/.ssh/known_hosts",
+				h.knownHostsName(), len(repos), repos[0], h.port, h.hostname))
+			continue
+		}
+
+		pinned := pins[strings.ToLower(host)]
+		if len(pinned) == 0 {
+			pinned = pins[strings.ToLower(h.hostname)]
+		}
+		if len(pinned) == 0 {
+			continue
+		}
+		for _, k := range known {
+			if fp := ssh.FingerprintSHA256(k); !contains(pinned, fp) {
+				problems = append(problems, fmt.Sprintf(
+					"the %s key known for %s (%s) does not match the fingerprints pinned for it in the config",
+					k.Type(), h.knownHostsName(), fp))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("SSH host key check failed, nothing was changed:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// sshRemote returns the host and port of url if it is reached over SSH,
+// either as ssh://[user@]host[:port]/path or as [user@]host:path. The port
+// is empty when url doesn't give one.
+func sshRemote(url string) (string, string, bool) {
+
+	for _, scheme := range []string{"ssh://", "git+ssh://", "ssh+git://"} {
+		if strings.HasPrefix(url, scheme) {
+			hostport := strings.TrimPrefix(url, scheme)
+			if i := strings.Index(hostport, "/"); i >= 0 {
+				hostport = hostport[:i]
+			}
+			if i := strings.LastIndex(hostport, "@"); i >= 0 {
+				hostport = hostport[i+1:]
+			}
+			if host, port, err := net.SplitHostPort(hostport); err == nil {
+				return host, port, true
+			}
+			return strings.Trim(hostport, "[]"), "", true
+		}
+	}
+
+	if strings.Contains(url, "://") || filepath.IsAbs(url) {
+		return "", "", false
+	}
+	i := strings.Index(url, ":")
+	if i < 0 || strings.Contains(url[:i], "/") {
+		return "", "", false
+	}
+	host := url[:i]
+	if j := strings.LastIndex(host, "@"); j >= 0 {
+		host = host[j+1:]
+	}
+	return host, "", true
+}
+
+// resolveSSHHost asks ssh -G how it would connect to host, so that aliases,
+// ports and known_hosts locations from ~/.ssh/config are honored. Without
+// ssh the defaults are assumed.
+func resolveSSHHost(ctx context.Context, host, port string) sshHost {
+
+	home, _ := os.UserHomeDir()
+	if port == "" {
+		port = "22"
+	}
+	h := sshHost{
+		hostname: host,
+		port:     port,
+		knownHosts: []string{
+			filepath.Join(home, ".ssh", "known_hosts"),
+			"/etc/ssh/ssh_known_hosts",
+		},
+	}
+
+	args := []string{"-G", host}
+	if port != "22" {
+		args = append([]string{"-p", port}, args...)
+	}
+	out, err := exec.CommandContext(ctx, "ssh", args...).Output()
+	if err != nil {
+		return h
+	}
+
+	h.knownHosts = nil
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "hostname":
+			h.hostname = fields[1]
+		case "port":
+			h.port = fields[1]
+		case "stricthostkeychecking":
+			h.strict = strings.ToLower(fields[1])
+		case "userknownhostsfile", "globalknownhostsfile":
+			for _, f := range fields[1:] {
+				if strings.HasPrefix(f, "~/") {
+					f = filepath.Join(home, f[2:])
+				}
+				h.knownHosts = append(h.knownHosts, f)
+			}
+		}
+	}
+	return h
+}
+
+// knownHostKeys returns the keys recorded for host in files, leaving out
+// revoked keys.
+func knownHostKeys(files []string, host string) []ssh.PublicKey {
+
+	var keys []ssh.PublicKey
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		// Lines are parsed one at a time so that a malformed one doesn't
+		// hide those after it.
+		for _, line := range strings.Split(string(data), "\n") {
+			marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+			if err != nil {
+				continue
+			}
+			if marker != "revoked" && matchKnownHost(hosts, host) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// matchKnownHost reports whether host matches the patterns of a known_hosts
+// line, including hashed entries, wildcards and negations.
+func matchKnownHost(patterns []string, host string) bool {
+
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		var ok bool
+		if strings.HasPrefix(pattern, "|1|") {
+			ok = matchHashedHost(pattern, host)
+		} else {
+			ok, _ = path.Match(strings.ToLower(pattern), strings.ToLower(host))
+		}
+
+		if ok && negated {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+// matchHashedHost checks host against a |1|salt|hash known_hosts entry.
+func matchHashedHost(entry, host string) bool {
+	parts := strings.Split(entry, "|")
+	if len(parts) != 4 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	cpuProfile string
+	memProfile string
+	traceFile  string
+
+	cpuProfileFile *os.File
+	traceOutFile   *os.File
+)
+
+// startProfiling begins whichever of the CPU profile and execution trace
+// were asked for.
+func startProfiling() error {
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return errors.Wrapf(err, "unable to create cpu profile [%s]", cpuProfile)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return errors.Wrap(err, "unable to start cpu profile")
+		}
+		cpuProfileFile = f
+	}
+
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			return errors.Wrapf(err, "unable to create trace [%s]", traceFile)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return errors.Wrap(err, "unable to start trace")
+		}
+		traceOutFile = f
+	}
+
+	return nil
+}
+
+// stopProfiling finishes any running profiles and writes the heap profile.
+// It is safe to call when profiling was never started.
+func stopProfiling() {
+
+	if cpuProfileFile != nil {
+		pprof.StopCPUProfile()
+		cpuProfileFile.Close()
+		cpuProfileFile = nil
+	}
+
+	if traceOutFile != nil {
+		trace.Stop()
+		traceOutFile.Close()
+		traceOutFile = nil
+	}
+
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "unable to create memory profile [%s]", memProfile).Error())
+			return
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			logger.Error(errors.Wrap(err, "unable to write memory profile").Error())
+		}
+	}
+}
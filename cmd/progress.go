@@ -0,0 +1,178 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/id9051/got/progress"
+	"github.com/spf13/viper"
+)
+
+var (
+	showProgress  bool
+	noCount       bool
+	streamResults bool
+
+	// progressActive is set while walkDirectories is drawing a progress line.
+	progressActive bool
+)
+
+// gitOutputBuffer holds per-repository output while the progress line owns
+// the terminal.
+var gitOutputBuffer outputBuffer
+
+const progressBarWidth = 30
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progressObservers are told about every recursive run, on top of the
+// progress line.
+var progressObservers []progress.Observer
+
+// ObserveProgress has o told of the progress of every recursive run, so that
+// a program embedding got can show it its own way.
+func ObserveProgress(o progress.Observer) {
+	progressObservers = append(progressObservers, o)
+}
+
+// progressTracker renders a single, continuously redrawn status line on
+// stderr from the events of a progress.Tracker. It draws a bar and
+// percentage against the repositories found so far, marking the total with
+// a + until discovery completes; with --no-count a spinner with running
+// totals is shown instead.
+type progressTracker struct {
+	mu     sync.Mutex
+	out    io.Writer
+	state  progress.Snapshot
+	frame  int
+	ticker *time.Ticker
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{out: os.Stderr, state: progress.Snapshot{Started: time.Now()}}
+}
+
+// start begins redrawing the progress line in the background so the spinner
+// keeps moving while a slow git command runs. With spinner: false in the
+// config file nothing moves between events, and the line is only redrawn
+// when a repository starts or ends.
+func (p *progressTracker) start() {
+	p.quit = make(chan struct{})
+	if !viper.GetBool("spinner") {
+		return
+	}
+	p.ticker = time.NewTicker(100 * time.Millisecond)
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-p.ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.render()
+				p.mu.Unlock()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Observe redraws the line for e. When a repository ends with --stream its
+// output is printed straight away, above the progress line, rather than at
+// the end.
+func (p *progressTracker) Observe(e progress.Event, s progress.Snapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = s
+
+	switch e.Type {
+	case progress.Found, progress.Discovered:
+		return
+	case progress.Ended:
+		gitOutputBuffer.endRepo(e.Path)
+		if streamResults {
+			fmt.Fprint(p.out, "\r\033[K")
+			flushGitOutput()
+		}
+	}
+	p.render()
+}
+
+// stop halts the redraw loop and clears the progress line.
+func (p *progressTracker) stop() {
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	close(p.quit)
+	p.wg.Wait()
+	fmt.Fprint(p.out, "\r\033[K")
+}
+
+func (p *progressTracker) render() {
+	s := p.state
+	elapsed := time.Since(s.Started).Truncate(time.Second)
+
+	current := ""
+	if s.Current != "" {
+		current = displayName(s.Current)
+	}
+	if s.Holder != "" {
+		current += " (waiting for " + s.Holder + ")"
+	}
+
+	var line string
+	if noCount || s.Total == 0 {
+		line = fmt.Sprintf("%d repositories (%s) ", s.Done, elapsed)
+		if p.ticker != nil {
+			line = spinnerFrames[p.frame%len(spinnerFrames)] + " " + line
+		}
+	} else {
+		filled := s.Done * progressBarWidth / s.Total
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		more := "+"
+		if s.Complete {
+			more = ""
+		}
+		line = fmt.Sprintf("[%s%s] %3d%% (%d/%d%s) ",
+			strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+			s.Done*100/s.Total, s.Done, s.Total, more)
+	}
+
+	// A line that wraps can't be redrawn in place, so the path gives way
+	// to keep it on one row, with a column spare for the cursor.
+	if width := widthOf(os.Stderr); width > 0 {
+		current = truncateMiddle(current, width-utf8.RuneCountInString(line)-1)
+	}
+	fmt.Fprint(p.out, "\r\033[K"+line+current)
+}
+
+// flushGitOutput writes everything held back during the run to stdout.
+func flushGitOutput() {
+	gitOutputBuffer.WriteTo(os.Stdout)
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// allowProtected lets destructive commands delete or rewrite protected
+// branches, set by --allow-protected.
+var allowProtected bool
+
+// protectedPattern returns the pattern of the protectedBranches in the
+// config file that branch matches:
+//
+//	protectedBranches: [main, release/*]
+//
+// Patterns are matched against the whole branch name, so release/* matches
+// release/1.2 but not hotfix/release.
+func protectedPattern(branch string) (string, bool) {
+	for _, pattern := range viper.GetStringSlice("protectedBranches") {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// guardProtected returns an error when branch, which the command being run
+// is about to delete or rewrite, is protected, unless --allow-protected is
+// given.
+func guardProtected(branch string) error {
+
+	if allowProtected {
+		return nil
+	}
+	pattern, ok := protectedPattern(branch)
+	if !ok {
+		return nil
+	}
+	detail := branch
+	if pattern != branch {
+		detail = fmt.Sprintf("%s (matches %s)", branch, pattern)
+	}
+	operation := "deleting a branch"
+	if running != nil {
+		operation = running.Operation
+	}
+	return errors.Errorf("%s refused on protected branch %s, pass --allow-protected to run anyway", operation, detail)
+}
@@ -0,0 +1,435 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// forgeTimeout is how long a request to a forge's API may take.
+const forgeTimeout = 30 * time.Second
+
+// prsCmd represents the prs command
+var prsCmd = &cobra.Command{
+	Use:   "prs directory",
+	Short: "List open pull requests that are yours",
+	Long: `Prs lists the open pull requests of a repository, or with -r of every
+repository beneath a directory, that you opened or are assigned to, for
+repositories whose origin is on GitHub or GitLab. Merge requests count as
+pull requests on GitLab.
+
+The APIs are called with the token in GITHUB_TOKEN (or GH_TOKEN) and
+GITLAB_TOKEN; repositories on a forge without a token are skipped. Tokens
+only go to github.com, gitlab.com and the hosts listed in the forges section
+of your own config file, as a project's .got.yaml can't list any:
+
+  forges:
+    - host: git.example.com
+      type: gitlab
+    - host: github.example.com
+      type: github
+      api: https://github.example.com/api/v3`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		return runOperation(ctx, args[0], prs)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(prsCmd)
+	describe(prsCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"List your open pull requests beneath ~/src", "got prs -r ~/src"},
+		},
+	})
+
+	prsCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively list the pull requests of subdirectories listed")
+}
+
+// forge is a GitHub or GitLab host, as the forges section of the config file
+// describes it.
+type forge struct {
+	Host string `mapstructure:"host"`
+	Type string `mapstructure:"type"`
+	API  string `mapstructure:"api"`
+}
+
+// pullRequest is an open pull request that is yours.
+type pullRequest struct {
+	number int
+	title  string
+	url    string
+	roles  []string
+}
+
+// forgeOf returns the forge of the host of a remote: github.com,
+// gitlab.com, or a host the forges in the config file list. A host is never
+// taken for a forge by its name alone, as that would hand a token to
+// github.example.net, whoever runs it.
+func forgeOf(host string) (forge, bool) {
+
+	var forges []forge
+	viper.UnmarshalKey("forges", &forges)
+	for _, f := range forges {
+		if strings.EqualFold(f.Host, host) && (f.Type == "github" || f.Type == "gitlab") {
+			if f.API == "" {
+				f.API = forgeAPI(f.Type, host)
+			}
+			f.API = strings.TrimSuffix(f.API, "/")
+			return f, true
+		}
+	}
+
+	for _, kind := range []string{"github", "gitlab"} {
+		if strings.EqualFold(host, kind+".com") {
+			return forge{Host: host, Type: kind, API: forgeAPI(kind, kind+".com")}, true
+		}
+	}
+	return forge{}, false
+}
+
+// forgeAPI returns the default API URL of a forge of kind on host.
+func forgeAPI(kind, host string) string {
+	switch {
+	case kind == "github" && host == "github.com":
+		return "https://api.github.com"
+	case kind == "github":
+		return "https://" + host + "/api/v3"
+	}
+	return "https://" + host + "/api/v4"
+}
+
+// token returns the API token of f from the environment.
+func (f forge) token() (string, string) {
+	vars := []string{"GITLAB_TOKEN"}
+	if f.Type == "github" {
+		vars = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	}
+	for _, name := range vars {
+		if token := os.Getenv(name); token != "" {
+			return token, name
+		}
+	}
+	return "", strings.Join(vars, " or ")
+}
+
+// get calls the API of f at endpoint and decodes the JSON it answers with
+// into v.
+func (f forge) get(ctx context.Context, endpoint string, v interface{}) error {
+	_, err := f.request(ctx, f.API+endpoint, v)
+	return err
+}
+
+// maxForgePages caps how many pages of a listing are read.
+const maxForgePages = 10
+
+// getPages calls the API of f at endpoint and at the next pages it links
+// to, both GitHub and GitLab answering with a Link header, handing each
+// page to page to decode.
+func (f forge) getPages(ctx context.Context, endpoint string, page func(data json.RawMessage) error) error {
+
+	next := f.API + endpoint
+	for n := 0; next != "" && n < maxForgePages; n++ {
+		var data json.RawMessage
+		var err error
+		if next, err = f.request(ctx, next, &data); err != nil {
+			return err
+		}
+		if err := page(data); err != nil {
+			return err
+		}
+		// The token only goes to the API it is for.
+		if !strings.HasPrefix(next, f.API+"/") {
+			next = ""
+		}
+	}
+	return nil
+}
+
+// linkNext matches the next page in a Link header.
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// request calls the API of f at target, decodes the JSON it answers with
+// into v and returns the URL of the next page, if there is one.
+func (f forge) request(ctx context.Context, target string, v interface{}) (string, error) {
+	header, err := f.requestHeader(ctx, target, v)
+	if err != nil {
+		return "", err
+	}
+	if m := linkNext.FindStringSubmatch(header.Get("Link")); m != nil {
+		return m[1], nil
+	}
+	return "", nil
+}
+
+// requestHeader calls the API of f at target, decodes the JSON it answers
+// with into v and returns the header of the answer.
+func (f forge) requestHeader(ctx context.Context, target string, v interface{}) (http.Header, error) {
+
+	token, _ := f.token()
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if f.Type == "github" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	} else {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+
+	// Redirects would carry the token along to wherever they lead.
+	client := &http.Client{Timeout: forgeTimeout, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" && !strings.HasSuffix(resp.Status, apiErr.Message) {
+			return resp.Header, forgeStatusError{code: resp.StatusCode, msg: fmt.Sprintf("%s: %s", resp.Status, apiErr.Message)}
+		}
+		return resp.Header, forgeStatusError{code: resp.StatusCode, msg: resp.Status}
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// forgeStatusError is an API answering with a status other than 2xx.
+type forgeStatusError struct {
+	code int
+	msg  string
+}
+
+func (e forgeStatusError) Error() string { return e.msg }
+
+// forgeUser is a user as the GitHub and GitLab APIs return one.
+type forgeUser struct {
+	Login    string `json:"login"`
+	Username string `json:"username"`
+}
+
+func (u forgeUser) name() string {
+	if u.Login != "" {
+		return u.Login
+	}
+	return u.Username
+}
+
+var (
+	forgeUsersMu sync.Mutex
+	forgeUsers   = map[string]string{}
+)
+
+// user returns the login of the user whose token is used with f, asking
+// the API once per host.
+func (f forge) user(ctx context.Context) (string, error) {
+
+	forgeUsersMu.Lock()
+	defer forgeUsersMu.Unlock()
+	if login, ok := forgeUsers[f.API]; ok {
+		return login, nil
+	}
+
+	var u forgeUser
+	if err := f.get(ctx, "/user", &u); err != nil {
+		return "", errors.Wrapf(err, "unable to identify you on %s", f.Host)
+	}
+	forgeUsers[f.API] = u.name()
+	return u.name(), nil
+}
+
+// forgeItem is a pull or merge request as the GitHub search and GitLab
+// merge request APIs return one.
+type forgeItem struct {
+	Number    int         `json:"number"`
+	IID       int         `json:"iid"`
+	Title     string      `json:"title"`
+	HTMLURL   string      `json:"html_url"`
+	WebURL    string      `json:"web_url"`
+	User      forgeUser   `json:"user"`
+	Author    forgeUser   `json:"author"`
+	Assignees []forgeUser `json:"assignees"`
+}
+
+// pullRequests returns the open pull requests of the project at path on f,
+// such as acme/api, that me opened or is assigned to. The forge does the
+// filtering, one query for each role, so that busy projects with hundreds
+// of open pull requests don't hide yours past the first page.
+func (f forge) pullRequests(ctx context.Context, project, me string) ([]pullRequest, error) {
+
+	var endpoints []string
+	if f.Type == "gitlab" {
+		base := "/projects/" + url.PathEscape(project) + "/merge_requests?state=opened&scope=all&per_page=100"
+		endpoints = []string{base + "&author_username=" + url.QueryEscape(me), base + "&assignee_username=" + url.QueryEscape(me)}
+	} else {
+		base := "/search/issues?per_page=100&q=" + url.QueryEscape("is:pr is:open repo:"+project)
+		endpoints = []string{base + url.QueryEscape(" author:"+me), base + url.QueryEscape(" assignee:"+me)}
+	}
+
+	var items []forgeItem
+	seen := map[string]bool{}
+	for _, endpoint := range endpoints {
+		err := f.getPages(ctx, endpoint, func(data json.RawMessage) error {
+			var page []forgeItem
+			if f.Type == "gitlab" {
+				if err := json.Unmarshal(data, &page); err != nil {
+					return err
+				}
+			} else {
+				var found struct {
+					Items []forgeItem `json:"items"`
+				}
+				if err := json.Unmarshal(data, &found); err != nil {
+					return err
+				}
+				page = found.Items
+			}
+			for _, item := range page {
+				key := item.HTMLURL + item.WebURL
+				if !seen[key] {
+					seen[key] = true
+					items = append(items, item)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Number+items[i].IID < items[j].Number+items[j].IID })
+
+	var prs []pullRequest
+	for _, item := range items {
+		pr := pullRequest{number: item.Number, title: item.Title, url: item.HTMLURL}
+		author := item.User.name()
+		if f.Type == "gitlab" {
+			pr.number, pr.url, author = item.IID, item.WebURL, item.Author.name()
+		}
+		if strings.EqualFold(author, me) {
+			pr.roles = append(pr.roles, "author")
+		}
+		for _, a := range item.Assignees {
+			if strings.EqualFold(a.name(), me) {
+				pr.roles = append(pr.roles, "assignee")
+				break
+			}
+		}
+		if len(pr.roles) > 0 {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, nil
+}
+
+func prs(ctx context.Context, path string) error {
+
+	if !isRepository(path) {
+		if recursive {
+			return nil
+		}
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	remote, err := remoteURL(ctx, path)
+	if err != nil || remote == "" {
+		repoSkipped(path, "Skipped (no origin)")
+		return nil
+	}
+	parts := strings.Split(normalizeRemoteURL(remote), "/")
+	f, ok := forgeOf(parts[0])
+	if !ok || len(parts) < 3 {
+		repoSkipped(path, "Skipped (origin is not on github.com, gitlab.com or a forge in the config)")
+		return nil
+	}
+	if token, vars := f.token(); token == "" {
+		repoSkipped(path, "Skipped (no %s)", vars)
+		return nil
+	}
+	expired, missing := f.checkToken(ctx)
+	if expired != nil {
+		repoFailed(path, expired)
+		return nil
+	}
+
+	me, err := f.user(ctx)
+	if err == nil {
+		var found []pullRequest
+		found, err = f.pullRequests(ctx, strings.Join(parts[1:], "/"), me)
+		if err == nil {
+			reportPullRequests(path, found)
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// A public repository needs no scope, so a missing one only explains a
+	// request that failed.
+	if missing != nil {
+		err = errors.Errorf("%s: %s", err, missing)
+	}
+	repoFailed(path, err)
+	return nil
+}
+
+// reportPullRequests reports the pull requests found for path, listing them
+// beneath the result.
+func reportPullRequests(path string, found []pullRequest) {
+
+	if len(found) == 0 {
+		repoSucceeded(path, "No open pull requests")
+		return
+	}
+
+	var out strings.Builder
+	for _, pr := range found {
+		fmt.Fprintf(&out, "  #%d %s (%s)\n    %s\n", pr.number, pr.title, strings.Join(pr.roles, ", "), pr.url)
+	}
+	noun := "pull requests"
+	if len(found) == 1 {
+		noun = "pull request"
+	}
+	repoSucceededWithOutput(path, []byte(out.String()), "%d open %s", len(found), noun)
+}
@@ -0,0 +1,195 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDryRun bool
+	pruneYes    bool
+)
+
+// pruneGoneCmd represents the prune-gone command
+var pruneGoneCmd = &cobra.Command{
+	Use:   "prune-gone directory",
+	Short: "Delete merged local branches whose upstream is gone",
+	Long: `Prune-gone runs git fetch --prune in a repository, or with -r in every
+repository beneath a directory, and then deletes the local branches whose
+upstream branch the fetch found gone and that are fully merged into the
+current branch.
+
+The branches to delete are listed first and nothing is deleted until that
+is confirmed, or with --yes; --dry-run stops after the list. Branches are
+deleted with git branch -d, so unmerged work is never lost.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if err := sshPreflight(ctx, args[0]); err != nil {
+			return err
+		}
+
+		plan, err := planPruneGone(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if pruneDryRun || len(plan) == 0 {
+			return nil
+		}
+		if !pruneYes && prompt(fmt.Sprintf("Delete %d branches? [y/N] ", branchCount(plan))) != "y" {
+			return nil
+		}
+
+		return runOperation(ctx, args[0], func(ctx context.Context, path string) error {
+			return pruneGone(ctx, path, plan[path])
+		})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pruneGoneCmd)
+	describe(pruneGoneCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"List the branches that would be deleted beneath ~/src", "got prune-gone -r --dry-run ~/src"},
+			{"Delete them without asking", "got prune-gone -r --yes ~/src"},
+		},
+	})
+
+	pruneGoneCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively prune subdirectories listed")
+	pruneGoneCmd.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "List the branches that would be deleted without deleting them")
+	pruneGoneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Delete the branches without asking first")
+}
+
+// planPruneGone prunes the remote-tracking branches of every repository
+// prune-gone would touch and lists, by repository, the local branches that
+// are merged and whose upstream is now gone.
+func planPruneGone(ctx context.Context, root string) (map[string][]string, error) {
+
+	plan := map[string][]string{}
+	check := func(path string) error {
+		if !hasRemote(ctx, path) {
+			return nil
+		}
+
+		unlock, err := lockRepo(ctx, path, func(holder string) {
+			logger.Info(fmt.Sprintf("%s [%s]:  %s", styles.Muted(iconWaiting), styles.Path(displayName(path)), styles.Muted("Waiting for "+holder)),
+				"path", path, "holder", holder)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn(err.Error(), "path", path)
+			return nil
+		}
+		defer unlock()
+
+		if out, err := gitCommand(ctx, path, "fetch", "--prune", "--quiet").CombinedOutput(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn(fmt.Sprintf("[%s] unable to prune: %s", displayName(path), strings.TrimSpace(string(out))), "path", path)
+			return nil
+		}
+
+		branches, err := localBranches(ctx, path)
+		if err != nil {
+			logger.Warn(err.Error(), "path", path)
+			return nil
+		}
+		for _, b := range branches {
+			if !b.gone || !b.merged {
+				continue
+			}
+			if err := guardProtected(b.name); err != nil {
+				logger.Warn(fmt.Sprintf("[%s] keeping %s: %s", displayName(path), b.name, err), "path", path, "branch", b.name)
+				continue
+			}
+			plan[path] = append(plan[path], b.name)
+		}
+		return nil
+	}
+
+	if recursive {
+		if err := walkRepositories(ctx, root, check); err != nil {
+			return nil, err
+		}
+	} else if !isRepository(root) {
+		return nil, errors.Errorf("[%s] is not a git repository", root)
+	} else if err := check(root); err != nil {
+		return nil, err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("No merged branches with a gone upstream")
+		return plan, nil
+	}
+
+	paths := make([]string, 0, len(plan))
+	for path := range plan {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Printf("%d merged branches with a gone upstream:\n", branchCount(plan))
+	for _, path := range paths {
+		fmt.Printf("[%s]\n", styles.Path(displayName(path)))
+		for _, name := range plan[path] {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	return plan, nil
+}
+
+// branchCount returns how many branches plan deletes.
+func branchCount(plan map[string][]string) int {
+	n := 0
+	for _, names := range plan {
+		n += len(names)
+	}
+	return n
+}
+
+// pruneGone deletes the branches of the repository at path.
+func pruneGone(ctx context.Context, path string, branches []string) error {
+
+	if len(branches) == 0 {
+		return nil
+	}
+
+	args := append([]string{"branch", "-d"}, branches...)
+	if err := runner.Run(ctx, path, repoCapture(path), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, err)
+	} else {
+		repoSucceeded(path, "Deleted %s", strings.Join(branches, ", "))
+	}
+
+	return nil
+}
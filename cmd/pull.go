@@ -15,41 +15,59 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
 
+	"github.com/id9051/got/internal/git"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
-var recursive bool
+var (
+	recursive bool
+	ifBehind  bool
+)
 
 // pullCmd represents the pull command
 var pullCmd = &cobra.Command{
 	Use:   "pull directory",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Short: "Pull the repositories beneath a directory",
+	Long: `Pull runs git pull in a repository, or with -r in every repository beneath a
+directory. Repositories without a remote, and bare repositories, are skipped;
+with --if-behind so are those already up to date with their upstream.
+
+Each result shows the range of commits pulled and the totals of what
+changed, with the files changed listed beneath it. An override in the
+config file can give repositories a pull strategy of rebase, ff-only or
+merge.`,
+	ValidArgsFunction: completeDirectory,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return errors.New("directory argument is required")
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
 		}
-		if recursive {
-			return pullWalk(args[0])
+		if _, g, ok := groupOf(args[0]); ok && g.IfBehind != nil && !cmd.Flags().Changed("if-behind") {
+			ifBehind = *g.IfBehind
+		}
+		if err := sshPreflight(ctx, args[0]); err != nil {
+			return err
 		}
-		return pull(args[0])
+		return runOperation(ctx, args[0], pull)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(pullCmd)
+	describe(pullCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Pull every repository beneath ~/src", "got pull -r ~/src"},
+			{"Only pull repositories that are behind their upstream", "got pull -r --if-behind ~/src"},
+		},
+	})
 
 	// Here you will define your flags and configuration settings.
 
@@ -61,50 +79,109 @@ func init() {
 	// is called directly, e.g.:
 	// pullCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	pullCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively pull subdirectories listed")
+	pullCmd.Flags().DurationVar(&timeBudget, "time-budget", 0, "With -r, stop starting repositories after this long, most stale first, and keep the rest for got retry --remaining")
+	pullCmd.Flags().BoolVar(&ifBehind, "if-behind", false, "Ask the remote with ls-remote first and only pull repositories with upstream changes")
 
 }
 
-func pull(path string) error {
-
-	_, err := os.Stat(filepath.Join(path, ".git"))
-	if err != nil {
+func pull(ctx context.Context, path string) error {
 
+	if !isRepository(path) {
 		if recursive {
 			return nil
 		}
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	if git.IsBare(path) {
+		repoSkipped(path, "Skipped (bare repository)")
+		return nil
+	}
 
-		return errors.Wrapf(err, "[%s] is not a git repository", path)
+	if !hasRemote(ctx, path) {
+		repoSkipped(path, "Skipped (no remote)")
+		return nil
 	}
 
-	pullCmd := exec.Command("git", fmt.Sprintf("--work-tree=%s", path), fmt.Sprintf("--git-dir=%s", filepath.Join(path, ".git")), "pull")
+	if ifBehind {
+		if current, err := upToDate(ctx, path); err == nil && current {
+			repoSucceeded(path, "Up to date")
+			return nil
+		}
+	}
 
-	if err := pullCmd.Run(); err != nil {
-		log.Printf("[%s]: ERROR %v\n", path, err)
-	} else {
-		log.Printf("[%s]:  Success\n", path)
+	before, _ := gitOutput(ctx, path, "rev-parse", "HEAD")
+	if err := runner.Run(ctx, path, repoCapture(path), pullArgs(path)...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, err)
+		return nil
+	}
+
+	after, _ := gitOutput(ctx, path, "rev-parse", "HEAD")
+	if before == "" || after == before {
+		repoSucceeded(path, "Up to date")
+		return nil
 	}
 
+	// Show what came down: the files changed beneath the result line, and
+	// the totals and the range pulled in the result itself.
+	span := shortCommit(before) + ".." + shortCommit(after)
+	stat, _ := gitOutput(ctx, path, "diff", "--stat", before, after)
+	shortstat, _ := gitOutput(ctx, path, "diff", "--shortstat", before, after)
+	var files bytes.Buffer
+	if lines := strings.Split(stat, "\n"); len(lines) > 1 {
+		// The last line of --stat repeats the --shortstat totals.
+		for _, line := range lines[:len(lines)-1] {
+			fmt.Fprintf(&files, "  %s\n", strings.TrimSpace(line))
+		}
+	}
+	if shortstat == "" {
+		repoSucceededWithOutput(path, files.Bytes(), "Updated %s", span)
+	} else {
+		repoSucceededWithOutput(path, files.Bytes(), "Updated %s: %s", span, shortstat)
+	}
 	return nil
 }
 
-func pullWalk(path string) error {
+// shortCommit abbreviates a commit hash.
+func shortCommit(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
 
-	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+// upToDate reports whether HEAD already contains the tip of its upstream
+// branch on the remote. It asks the remote with ls-remote, which is much
+// cheaper than a fetch when nothing has changed.
+func upToDate(ctx context.Context, path string) (bool, error) {
 
-		// Usually usually happens when a director is deleted. If exists when filepath.Walk
-		// is called but then the pull removes it. So we get a "No such file or directory"
-		// error. We're returning nil so that processing continues.
-		if err != nil {
-			log.Println(errors.Wrapf(err, "error walking filepath [%s]", path).Error())
-			return nil
-		}
+	branch, err := gitOutput(ctx, path, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return false, errors.Wrapf(err, "[%s] HEAD is detached", path)
+	}
 
-		if !info.IsDir() {
-			return nil
-		} else if filepath.Base(path) == ".git" {
-			return filepath.SkipDir
-		}
+	remote, err := gitOutput(ctx, path, "config", "--get", "branch."+branch+".remote")
+	if err != nil {
+		return false, errors.Wrapf(err, "[%s] %s has no upstream", path, branch)
+	}
+	merge, err := gitOutput(ctx, path, "config", "--get", "branch."+branch+".merge")
+	if err != nil {
+		return false, errors.Wrapf(err, "[%s] %s has no upstream", path, branch)
+	}
 
-		return pull(path)
-	})
+	out, err := gitOutput(ctx, path, "ls-remote", remote, merge)
+	if err != nil {
+		return false, errors.Wrapf(err, "[%s] unable to query %s", path, remote)
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	// is-ancestor also fails when the remote commit isn't known locally yet,
+	// which is exactly the case that needs a pull.
+	return gitCommand(ctx, path, "merge-base", "--is-ancestor", fields[0], "HEAD").Run() == nil, nil
 }
@@ -0,0 +1,163 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// example is a sample invocation shown in help and the command reference.
+type example struct {
+	Comment string `json:"comment"`
+	Command string `json:"command"`
+}
+
+// commandInfo is what got knows about one of its commands beyond what
+// cobra does. It drives the Examples section of help, the note on whether
+// the command changes repositories, and got commands. A destructive command
+// is a mutating one that can discard work, and refuses to delete or rewrite
+// protected branches.
+type commandInfo struct {
+	Operation   string    `json:"operation"`
+	Short       string    `json:"short"`
+	Mutating    bool      `json:"mutating"`
+	Destructive bool      `json:"destructive,omitempty"`
+	Examples    []example `json:"examples,omitempty"`
+
+	cmd *cobra.Command
+}
+
+// registry holds every described command, keyed by its full name, such as
+// "worktree add".
+var registry = map[string]*commandInfo{}
+
+// running is the registered command being run, set before it runs. It is
+// nil for commands that aren't described.
+var running *commandInfo
+
+// describe registers cmd with info and fills in its help from it. It must
+// be called after cmd has been added to its parent.
+func describe(cmd *cobra.Command, info commandInfo) {
+
+	info.cmd = cmd
+	info.Operation = strings.TrimPrefix(cmd.CommandPath(), RootCmd.Name()+" ")
+	info.Short = cmd.Short
+	registry[info.Operation] = &info
+
+	var lines []string
+	for _, e := range info.Examples {
+		lines = append(lines, "  # "+e.Comment, "  "+e.Command)
+	}
+	cmd.Example = strings.Join(lines, "\n")
+
+	mode := "It is read-only and never changes a repository."
+	if info.Mutating {
+		mode = "It changes the repositories it runs in."
+	}
+	if info.Destructive {
+		mode = "It can discard work, and refuses to delete or rewrite a protected branch\nunless --allow-protected is given."
+	}
+	if cmd.Long == "" {
+		cmd.Long = cmd.Short + "."
+	}
+	cmd.Long += "\n\n" + mode
+}
+
+// describedCommands returns the registered commands sorted by name.
+func describedCommands() []*commandInfo {
+	var infos []*commandInfo
+	for _, info := range registry {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Operation < infos[j].Operation })
+	return infos
+}
+
+var commandsMarkdown bool
+
+// commandsCmd represents the commands command
+var commandsCmd = &cobra.Command{
+	Use:   "commands",
+	Short: "List got's commands and whether they change repositories",
+	Long: `Commands lists every got command with a one-line summary and whether it is
+read-only or changes the repositories it runs in. With --json each command
+is written as an object, including its examples; with --markdown a command
+reference is written instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		infos := describedCommands()
+
+		switch {
+		case jsonOutput:
+			for _, info := range infos {
+				writeJSON(info)
+			}
+		case commandsMarkdown:
+			writeCommandReference(infos)
+		default:
+			for _, info := range infos {
+				mode := "read-only"
+				if info.Destructive {
+					mode = "destructive"
+				} else if info.Mutating {
+					mode = "mutating"
+				}
+				fmt.Printf("%-26s %-11s %s\n", info.Operation, mode, info.Short)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(commandsCmd)
+	describe(commandsCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Write the command reference for the documentation", "got commands --markdown > docs/commands.md"},
+		},
+	})
+	commandsCmd.Flags().BoolVar(&commandsMarkdown, "markdown", false, "Write a Markdown command reference")
+}
+
+// writeCommandReference writes a Markdown section for each command in
+// infos, for the documentation.
+func writeCommandReference(infos []*commandInfo) {
+
+	fmt.Println("# got command reference")
+	for _, info := range infos {
+		fmt.Printf("\n## got %s\n\n", info.Operation)
+		fmt.Printf("    %s\n\n", info.cmd.UseLine())
+		fmt.Println(info.cmd.Long)
+		if len(info.Examples) > 0 {
+			fmt.Print("\n### Examples\n\n")
+			fmt.Println("```")
+			for _, e := range info.Examples {
+				fmt.Printf("# %s\n%s\n", e.Comment, e.Command)
+			}
+			fmt.Println("```")
+		}
+		if flags := info.cmd.NonInheritedFlags().FlagUsages(); flags != "" {
+			fmt.Print("\n### Flags\n\n")
+			fmt.Println("```")
+			fmt.Print(flags)
+			fmt.Println("```")
+		}
+	}
+}
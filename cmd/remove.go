@@ -0,0 +1,419 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// trashRetention is how long a removed repository can be restored with
+// --undo before it is purged for good.
+const trashRetention = 7 * 24 * time.Hour
+
+var (
+	forceRemove bool
+	undoRemove  bool
+)
+
+// removeCmd represents the remove command
+var removeCmd = &cobra.Command{
+	Use:   "remove repository",
+	Short: "Move a repository to the got trash",
+	Long: `Remove moves a repository into the got trash directory
+($XDG_DATA_HOME/got/trash, by default ~/.local/share/got/trash).
+
+Its short name under names and its paths in groups are taken out of the
+config files, and put back again when it is restored.
+
+Repositories with uncommitted changes or commits that have not been pushed
+are refused unless --force is given. Linked worktrees, and repositories that
+have them, are always refused, as moving either would break the other; use
+git worktree remove for those. Removed repositories can be restored
+with --undo for seven days, after which they are purged.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if undoRemove {
+			path := ""
+			if len(args) > 0 {
+				path = repoArg(args[0])
+			}
+			return restore(path)
+		}
+		if len(args) < 1 {
+			return usageErrorf("repository argument is required")
+		}
+		args[0] = repoArg(args[0])
+		return remove(ctx, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(removeCmd)
+	describe(removeCmd, commandInfo{
+		Mutating:    true,
+		Destructive: true,
+		Examples: []example{
+			{"Move a repository to the trash", "got remove ~/src/old-project"},
+			{"Restore it again", "got remove --undo ~/src/old-project"},
+		},
+	})
+
+	removeCmd.Flags().BoolVarP(&forceRemove, "force", "f", false, "Remove even if there are uncommitted changes or unpushed commits")
+	removeCmd.Flags().BoolVar(&undoRemove, "undo", false, "Restore the most recently removed repository, or the one removed from the given path")
+}
+
+func remove(ctx context.Context, path string) error {
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve [%s]", path)
+	}
+
+	if !isRepository(path) {
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	// A checkout on a protected branch is kept even with --force.
+	if branch, _ := git.Head(path); branch != "" {
+		if err := guardProtected(branch); err != nil {
+			return errors.Wrapf(err, "[%s]", path)
+		}
+	}
+
+	if !forceRemove {
+		if err := checkRemovable(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	entry, err := moveToTrash(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	// The repository is gone either way; a config file that can't be
+	// updated is left as it is.
+	forgotten, err := forgetRepository(path)
+	if err != nil {
+		logger.Warn(err.Error())
+	}
+	if len(forgotten) > 0 {
+		if data, err := json.Marshal(forgotten); err == nil {
+			ioutil.WriteFile(filepath.Join(entry, "config.json"), data, 0600)
+		}
+		repoSucceeded(path, "Removed, along with its names and group paths in the config (restore with got remove --undo)")
+		return nil
+	}
+
+	repoSucceeded(path, "Removed (restore with got remove --undo)")
+	return nil
+}
+
+// moveToTrash moves path into a new trash entry, recording where it came
+// from so it can be restored, and returns the entry's directory. A linked
+// worktree, or a repository with linked worktrees, is refused: the two
+// point at each other by path, and the one left behind would be broken.
+func moveToTrash(ctx context.Context, path string) (string, error) {
+
+	if err := checkNoWorktrees(ctx, path); err != nil {
+		return "", err
+	}
+
+	trash, err := trashDir()
+	if err != nil {
+		return "", err
+	}
+	purgeTrash(trash)
+
+	entry := filepath.Join(trash, strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.MkdirAll(entry, 0700); err != nil {
+		return "", errors.Wrapf(err, "unable to create trash entry [%s]", entry)
+	}
+	if err := ioutil.WriteFile(filepath.Join(entry, "origin"), []byte(path+"\n"), 0600); err != nil {
+		return "", errors.Wrapf(err, "unable to record origin of [%s]", path)
+	}
+	if err := moveDir(path, filepath.Join(entry, "repo")); err != nil {
+		os.RemoveAll(entry)
+		return "", errors.Wrapf(err, "unable to move [%s] to the trash", path)
+	}
+
+	return entry, nil
+}
+
+// checkNoWorktrees returns an error if the repository at path is a linked
+// worktree or has any.
+func checkNoWorktrees(ctx context.Context, path string) error {
+
+	if _, linked, err := commonDir(ctx, path); err != nil {
+		return err
+	} else if linked {
+		return errors.Errorf("[%s] is a linked worktree, use git worktree remove instead", path)
+	}
+
+	out, err := gitOutput(ctx, path, "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.Wrapf(err, "[%s] unable to list worktrees", path)
+	}
+	var worktrees []string
+	for _, line := range strings.Split(out, "\n") {
+		if wt := strings.TrimPrefix(line, "worktree "); wt != line && !samePath(wt, path) {
+			worktrees = append(worktrees, wt)
+		}
+	}
+	if len(worktrees) > 0 {
+		return errors.Errorf("[%s] has linked worktrees (%s), remove them with git worktree remove first", path, strings.Join(worktrees, ", "))
+	}
+	return nil
+}
+
+// moveDir moves the directory src to dst. When they are on different file
+// systems, which a rename can't cross, src is copied and then deleted.
+func moveDir(src, dst string) error {
+
+	err := os.Rename(src, dst)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// crossDevice reports whether err is a rename failing for crossing file
+// systems: EXDEV, or ERROR_NOT_SAME_DEVICE on Windows.
+func crossDevice(err error) bool {
+	le, ok := err.(*os.LinkError)
+	if !ok {
+		return false
+	}
+	errno, ok := le.Err.(syscall.Errno)
+	return ok && (errno == syscall.EXDEV || runtime.GOOS == "windows" && errno == 17)
+}
+
+// copyTree copies the directory src to dst, which must not exist, keeping
+// modes, modification times and symbolic links.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			// Kept writable by its owner, so that its contents can be
+			// copied in.
+			if err := os.Mkdir(target, mode.Perm()|0700); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			if err := copyFile(path, target, mode.Perm()); err != nil {
+				return err
+			}
+		default:
+			// Sockets, pipes and devices don't belong in a repository and
+			// can't be carried over.
+			return nil
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+// copyFile copies the regular file src to dst with mode perm.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checkRemovable returns an error describing why path would lose work if it
+// were removed.
+func checkRemovable(ctx context.Context, path string) error {
+
+	changes, err := gitOutput(ctx, path, "status", "--porcelain")
+	if err != nil {
+		return errors.Wrapf(err, "[%s] unable to read status", path)
+	}
+	if changes != "" {
+		return errors.Errorf("[%s] has uncommitted changes, use --force to remove anyway", path)
+	}
+
+	unpushed, err := gitOutput(ctx, path, "log", "--oneline", "--branches", "--not", "--remotes")
+	if err != nil {
+		return errors.Wrapf(err, "[%s] unable to list unpushed commits", path)
+	}
+	if unpushed != "" {
+		return errors.Errorf("[%s] has %d unpushed commit(s), use --force to remove anyway", path, len(strings.Split(unpushed, "\n")))
+	}
+
+	return nil
+}
+
+// restore moves a trashed repository back to where it was removed from,
+// and puts back the entries for it remove took out of the config files. An
+// empty path restores the most recently removed repository.
+func restore(path string) error {
+
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return errors.Wrapf(err, "unable to resolve [%s]", path)
+		}
+		path = abs
+	}
+
+	trash, err := trashDir()
+	if err != nil {
+		return err
+	}
+
+	entries, err := trashEntries(trash)
+	if err != nil {
+		return err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := filepath.Join(trash, entries[i])
+		origin, err := ioutil.ReadFile(filepath.Join(entry, "origin"))
+		if err != nil {
+			continue
+		}
+		original := strings.TrimSpace(string(origin))
+		if path != "" && original != path {
+			continue
+		}
+
+		if _, err := os.Stat(original); err == nil {
+			return errors.Errorf("[%s] already exists, not restoring over it", original)
+		}
+		if err := os.MkdirAll(filepath.Dir(original), 0755); err != nil {
+			return errors.Wrapf(err, "unable to create [%s]", filepath.Dir(original))
+		}
+		if err := moveDir(filepath.Join(entry, "repo"), original); err != nil {
+			return errors.Wrapf(err, "unable to restore [%s]", original)
+		}
+		if data, err := ioutil.ReadFile(filepath.Join(entry, "config.json")); err == nil {
+			var forgotten []configEntry
+			if err := json.Unmarshal(data, &forgotten); err == nil {
+				err = rememberRepository(forgotten)
+			}
+			if err != nil {
+				logger.Warn(fmt.Sprintf("[%s] unable to put its names and group paths back in the config: %s", original, err))
+			}
+		}
+		os.RemoveAll(entry)
+
+		repoSucceeded(original, "Restored")
+		return nil
+	}
+
+	if path != "" {
+		return errors.Errorf("no removed repository found for [%s]", path)
+	}
+	return errors.New("the trash is empty")
+}
+
+// purgeTrash deletes trash entries older than trashRetention.
+func purgeTrash(trash string) {
+
+	entries, err := trashEntries(trash)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-trashRetention).UnixNano()
+	for _, name := range entries {
+		stamp, _ := strconv.ParseInt(name, 10, 64)
+		if stamp < cutoff {
+			os.RemoveAll(filepath.Join(trash, name))
+		}
+	}
+}
+
+// trashEntries returns the names of the entries in trash, oldest first.
+func trashEntries(trash string) ([]string, error) {
+
+	infos, err := ioutil.ReadDir(trash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to read trash [%s]", trash)
+	}
+
+	var names []string
+	for _, info := range infos {
+		if _, err := strconv.ParseInt(info.Name(), 10, 64); err == nil && info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := strconv.ParseInt(names[i], 10, 64)
+		b, _ := strconv.ParseInt(names[j], 10, 64)
+		return a < b
+	})
+	return names, nil
+}
+
+// trashDir returns where removed repositories are kept, trash in got's
+// data directory.
+func trashDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to locate the data directory")
+	}
+	return filepath.Join(dir, "trash"), nil
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// withConfigFile writes a config file with content and makes it the one
+// config file read for the rest of the test.
+func withConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := configFiles
+	configFiles = []string{name}
+	t.Cleanup(func() { configFiles = saved })
+	return name
+}
+
+func TestRemoveForgetsAndRestoreRemembers(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	root := initRepos(t, 2)
+	repo, other := filepath.Join(root, "a"), filepath.Join(root, "b")
+
+	config := `# Repositories I work on.
+names:
+  app: ` + repo + `
+  lib: ` + other + `
+groups:
+  work:
+    paths: [` + repo + `, ` + other + `]
+    failFast: true
+  mine: [` + repo + `]
+  all: [` + root + `/*]
+`
+	name := withConfigFile(t, config)
+
+	if err := remove(context.Background(), repo); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := os.Stat(repo); !os.IsNotExist(err) {
+		t.Fatalf("%s is still there after remove", repo)
+	}
+
+	want := `# Repositories I work on.
+names:
+  lib: ` + other + `
+groups:
+  work:
+    paths: [` + other + `]
+    failFast: true
+  mine: []
+  all: [` + root + `/*]
+`
+	if got := readFile(t, name); got != want {
+		t.Errorf("config after remove =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := restore(repo); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !isRepository(repo) {
+		t.Fatalf("%s is not back after restore", repo)
+	}
+
+	want = `# Repositories I work on.
+names:
+  lib: ` + other + `
+  app: ` + repo + `
+groups:
+  work:
+    paths: [` + other + `, ` + repo + `]
+    failFast: true
+  mine: [` + repo + `]
+  all: [` + root + `/*]
+`
+	if got := readFile(t, name); got != want {
+		t.Errorf("config after restore =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRestoreKeepsNameGivenAgain(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	root := initRepos(t, 2)
+	repo, other := filepath.Join(root, "a"), filepath.Join(root, "b")
+	name := withConfigFile(t, "names:\n  app: "+repo+"\n")
+
+	if err := remove(context.Background(), repo); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	// The name goes to another repository while this one is in the trash.
+	if err := ioutil.WriteFile(name, []byte("names:\n  app: "+other+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restore(repo); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := readFile(t, name); !strings.Contains(got, "app: "+other) || strings.Contains(got, repo+"\n") {
+		t.Errorf("config after restore = %q, want app still naming %s", got, other)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRemoveRefusesProtectedBranch(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	withConfigFile(t, "")
+	saved := viper.Get("protectedBranches")
+	viper.Set("protectedBranches", []string{"release/*"})
+	t.Cleanup(func() { viper.Set("protectedBranches", saved) })
+
+	repo := filepath.Join(initRepos(t, 1), "a")
+	gitRun(t, repo, "checkout", "-q", "-b", "release/1.2")
+
+	forceRemove = true
+	defer func() { forceRemove = false }()
+	err := remove(context.Background(), repo)
+	if err == nil || !strings.Contains(err.Error(), "protected branch release/1.2 (matches release/*)") {
+		t.Fatalf("remove = %v, want it refused on the protected branch", err)
+	}
+	if !isRepository(repo) {
+		t.Fatalf("%s was removed", repo)
+	}
+
+	allowProtected = true
+	defer func() { allowProtected = false }()
+	if err := remove(context.Background(), repo); err != nil {
+		t.Fatalf("remove --allow-protected = %v", err)
+	}
+}
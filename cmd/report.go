@@ -0,0 +1,551 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+)
+
+// The icons that mark results, which a theme can replace.
+var (
+	iconSuccess = "✓"
+	iconError   = "✗"
+	iconSkipped = "⏭"
+	iconWaiting = "⧗"
+
+	// dash separates the reason from "skipped" in the headings of the
+	// results table.
+	dash = "—"
+)
+
+const (
+	statusSuccess = "success"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+)
+
+// formatVersionLatest is the newest version of the --json and --porcelain
+// formats. Bump it, and keep writing the old layout for older versions,
+// whenever either format changes in a way a script could notice.
+const formatVersionLatest = 1
+
+var (
+	jsonOutput bool
+
+	// porcelainOutput selects the tab-separated format for scripts. Its
+	// layout is <status>\t<path>\t<detail> and, for a given
+	// --format-version, will not change between releases, unlike the
+	// styled output.
+	porcelainOutput bool
+
+	// quiet keeps successes, skips and git output to themselves, leaving
+	// only failures and the summary of the run.
+	quiet bool
+
+	// verbosity is the number of times -v was given: once shows every git
+	// command as it runs, twice all git output as well.
+	verbosity int
+
+	// formatVersion is the version of the machine-readable formats asked
+	// for with --format-version.
+	formatVersion int
+)
+
+// operation is the name of the command being run, as reported in results.
+var operation string
+
+// resultHook, when set, takes every result in place of the console and the
+// machine-readable formats, for got ui.
+var resultHook func(result)
+
+// failures counts the repositories reported with repoFailed.
+var failures int32
+
+// result is the outcome of an operation in a single repository.
+type result struct {
+	Type      string  `json:"type"`
+	Version   int     `json:"version"`
+	Path      string  `json:"path"`
+	Operation string  `json:"operation"`
+	Status    string  `json:"status"`
+	Detail    string  `json:"detail,omitempty"`
+	Duration  float64 `json:"duration"`
+	Output    string  `json:"output,omitempty"`
+	Error     string  `json:"error,omitempty"`
+
+	branch string
+}
+
+// summary totals the results of a run.
+type summary struct {
+	Type      string  `json:"type"`
+	Version   int     `json:"version"`
+	Operation string  `json:"operation"`
+	Total     int     `json:"total"`
+	Succeeded int     `json:"succeeded"`
+	Skipped   int     `json:"skipped"`
+	Failed    int     `json:"failed"`
+	OtherVCS  int     `json:"otherVcs,omitempty"`
+	Duration  float64 `json:"duration"`
+}
+
+// repoState tracks a repository while an operation runs in it.
+type repoState struct {
+	started time.Time
+	output  bytes.Buffer
+}
+
+var (
+	resultsMu  sync.Mutex
+	results    []result
+	repoStates = map[string]*repoState{}
+)
+
+// startRepo begins tracking the operation in path.
+func startRepo(path string) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	repoStates[path] = &repoState{started: time.Now()}
+}
+
+// endRepo stops tracking path.
+func endRepo(path string) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	delete(repoStates, path)
+}
+
+// repoCapture returns a writer for git output in path that is kept for the
+// result but not shown, unless -vv asks for all git output.
+func repoCapture(path string) io.Writer {
+	if verbosity >= 2 && !machineOutput() {
+		return io.MultiWriter(repoConsoleOutput(path), captureWriter(path))
+	}
+	return captureWriter(path)
+}
+
+// captureWriter returns a writer onto the output kept for path's result.
+func captureWriter(path string) io.Writer {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	if state, ok := repoStates[path]; ok {
+		return &lockedWriter{w: &state.output}
+	}
+	return ioutil.Discard
+}
+
+// repoOutput returns where git output meant for the user should be written
+// for path. It is kept for the result as well, and with --json or
+// --porcelain, or --quiet, that is the only place it goes.
+func repoOutput(path string) io.Writer {
+	if machineOutput() || (quiet && verbosity < 2) {
+		return captureWriter(path)
+	}
+	return io.MultiWriter(repoConsoleOutput(path), captureWriter(path))
+}
+
+// consoleOutput returns where text for the user should be written.
+func consoleOutput() io.Writer {
+	if progressActive {
+		return &gitOutputBuffer
+	}
+	return os.Stdout
+}
+
+// repoConsoleOutput returns where text for the user about the repository at
+// path should be written, which is held to its share of the buffer while
+// the progress line is showing.
+func repoConsoleOutput(path string) io.Writer {
+	if progressActive {
+		return gitOutputBuffer.forRepo(path)
+	}
+	return os.Stdout
+}
+
+// repoSucceeded reports the outcome of a successful operation in path.
+func repoSucceeded(path, format string, args ...interface{}) {
+	detail := fmt.Sprintf(format, args...)
+	if record(path, statusSuccess, detail, nil) {
+		return
+	}
+	logResult(slog.LevelInfo, fmt.Sprintf("%s %s:  %s", styles.Success(iconSuccess), repoLabel(path), detail),
+		"path", path, "status", statusSuccess, "detail", detail)
+}
+
+// repoSucceededWithOutput reports a successful operation in path along with
+// output to show beneath the result line, where the output of repoOutput
+// would come before it.
+func repoSucceededWithOutput(path string, output []byte, format string, args ...interface{}) {
+	captureWriter(path).Write(output)
+	repoSucceeded(path, format, args...)
+	if !machineOutput() && !(quiet && verbosity < 2) {
+		repoConsoleOutput(path).Write(output)
+	}
+}
+
+// repoSkipped reports that path was deliberately left alone.
+func repoSkipped(path, format string, args ...interface{}) {
+	detail := fmt.Sprintf(format, args...)
+	if record(path, statusSkipped, detail, nil) {
+		return
+	}
+	logResult(slog.LevelInfo, fmt.Sprintf("%s %s:  %s", styles.Muted(iconSkipped), repoLabel(path), styles.Muted(detail)),
+		"path", path, "status", statusSkipped, "detail", detail)
+}
+
+// repoFailed reports that a git command failed in the repository at path.
+// A failure for want of credentials is reported as such, with guidance.
+func repoFailed(path string, err error) {
+	atomic.AddInt32(&failures, 1)
+	detail, label := "", "ERROR"
+	if auth, ok := authFailure(path, err); ok {
+		err, detail, label = auth, authRequired, "AUTH REQUIRED"
+	}
+	if record(path, statusFailed, detail, err) {
+		return
+	}
+	logResult(slog.LevelError, fmt.Sprintf("%s %s: %s %v", styles.Error(iconError), repoLabel(path), styles.Error(label), err),
+		"path", path, "status", statusFailed, "error", err.Error())
+}
+
+// repoLabel names the repository at path in a result line, along with the
+// branch it is on.
+func repoLabel(path string) string {
+	label := "[" + styles.Path(displayName(path)) + "]"
+	if branch, err := git.Head(path); err == nil && branch != "" {
+		label += " " + styles.Muted("("+branch+")")
+	}
+	return label
+}
+
+// logResult logs a repository's result line. While the progress line holds
+// results back for the end of the run they are left out, as the results
+// table shows them all then; with --stream they are logged as usual.
+func logResult(level slog.Level, msg string, attrs ...interface{}) {
+	if progressActive && !streamResults {
+		writeLogFile("%s", msg)
+		return
+	}
+	logger.Log(context.Background(), level, msg, attrs...)
+}
+
+// machineOutput reports whether results are being written for a program
+// rather than a person.
+func machineOutput() bool {
+	return jsonOutput || porcelainOutput || csvOutput || markdownOutput || lineTemplate != nil || resultHook != nil
+}
+
+// failureCount returns how many repositories have failed so far.
+func failureCount() int {
+	return int(atomic.LoadInt32(&failures))
+}
+
+// record keeps the result of an operation in path and, with --json,
+// --porcelain, --output csv or --format, writes it out. With --report
+// markdown it is left for the report at the end of the run. It reports
+// whether the result has been dealt with, in which case the caller should
+// not log it as text; with --quiet only failures are left to log.
+func record(path, status, detail string, err error) bool {
+
+	r := result{Type: "repository", Version: formatVersion, Path: path, Operation: operation, Status: status, Detail: detail}
+	r.branch, _ = git.Head(path)
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	resultsMu.Lock()
+	if state, ok := repoStates[path]; ok {
+		r.Duration = time.Since(state.started).Seconds()
+		r.Output = state.output.String()
+	}
+	results = append(results, r)
+	resultsMu.Unlock()
+
+	logRepoOutput(path, r.Output)
+
+	// The log file gets every result, whatever is shown on screen.
+	if machineOutput() || (quiet && status != statusFailed) {
+		icon, text := iconSuccess, detail
+		switch status {
+		case statusSkipped:
+			icon = iconSkipped
+		case statusFailed:
+			icon, text = iconError, "ERROR "+r.Error
+		}
+		label := "[" + path + "]"
+		if r.branch != "" {
+			label += " (" + r.branch + ")"
+		}
+		writeLogFile("%s %s:  %s", icon, label, text)
+	}
+
+	switch {
+	case resultHook != nil:
+		resultHook(r)
+	case quiet && status != statusFailed:
+	case jsonOutput:
+		writeJSON(r)
+	case porcelainOutput:
+		writePorcelain(r)
+	case csvOutput:
+		writeCSV(r)
+	case lineTemplate != nil:
+		writeTemplate(r)
+	case markdownOutput:
+		// The report is written once the run is over.
+	default:
+		return false
+	}
+	return true
+}
+
+// finishRun reports the run as a whole once every repository is done. The
+// summary is written with --json, as a line of text with --quiet, and
+// otherwise as a table of results after a recursive run. Then the metrics
+// file and the --report are written, a recursive run is reported to the
+// webhook, if one is configured, and the postRun hook runs.
+func finishRun(elapsed time.Duration) {
+
+	s := runSummary(elapsed)
+	defer postRunHook(s)
+	if recursive {
+		defer sendWebhook(s)
+	}
+	defer writeReport(s)
+	defer writeMetrics(s)
+
+	if !machineOutput() && !quiet {
+		if recursive {
+			writeResultsTable()
+		}
+		return
+	}
+
+	if jsonOutput {
+		writeJSON(s)
+	} else if markdownOutput {
+		writeMarkdownReport(machineWriter(), s)
+	} else if !machineOutput() {
+		skipped := fmt.Sprintf("%d skipped", s.Skipped)
+		if s.OtherVCS > 0 {
+			skipped += fmt.Sprintf(" (%d other VCS)", s.OtherVCS)
+		}
+		logger.Info(fmt.Sprintf("%s: %d repositories, %d succeeded, %s, %d failed (%s)",
+			s.Operation, s.Total, s.Succeeded, skipped, s.Failed, elapsed.Truncate(time.Millisecond)),
+			"operation", s.Operation, "total", s.Total, "succeeded", s.Succeeded, "skipped", s.Skipped, "failed", s.Failed, "otherVcs", s.OtherVCS)
+	}
+}
+
+// runSummary totals the results of the run.
+func runSummary(elapsed time.Duration) summary {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	s := summary{Type: "summary", Version: formatVersion, Operation: operation, Total: len(results), Duration: elapsed.Seconds()}
+	for _, r := range results {
+		switch r.Status {
+		case statusSuccess:
+			s.Succeeded++
+		case statusSkipped:
+			s.Skipped++
+			if outcomeCategory(r) == otherVCSCategory {
+				s.OtherVCS++
+			}
+		case statusFailed:
+			s.Failed++
+		}
+	}
+	return s
+}
+
+func writeJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	machineWriter().Write(append(data, '\n'))
+}
+
+// checkFormatVersion rejects a --format-version this got can't write.
+func checkFormatVersion() error {
+	if formatVersion < 1 || formatVersion > formatVersionLatest {
+		return errors.Errorf("unsupported --format-version %d (this got writes versions 1 to %d)", formatVersion, formatVersionLatest)
+	}
+	return nil
+}
+
+var porcelainHeader sync.Once
+
+// writeResultsTable writes every result as a row of a table sized to fit
+// the terminal, grouped by outcome under a heading with the group's count.
+// Failures come last, where they are easiest to find.
+func writeResultsTable() {
+
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+
+	if len(results) == 0 {
+		return
+	}
+
+	var order []string
+	grouped := map[string][]result{}
+	for _, r := range results {
+		c := outcomeCategory(r)
+		if _, ok := grouped[c]; !ok {
+			order = append(order, c)
+		}
+		grouped[c] = append(grouped[c], r)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return categoryRank(order[i]) < categoryRank(order[j]) })
+
+	header := []string{"REPOSITORY", "BRANCH", "RESULT", "DURATION"}
+	rows := map[string][][]string{}
+	var all [][]string
+	for _, c := range order {
+		for _, r := range grouped[c] {
+			var outcome string
+			switch r.Status {
+			case statusSuccess:
+				outcome = iconSuccess + " " + r.Detail
+			case statusSkipped:
+				outcome = iconSkipped + " " + r.Detail
+			default:
+				outcome = iconError + " " + r.Error
+			}
+			duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Millisecond)
+			row := []string{displayName(r.Path), r.branch, outcome, duration.String()}
+			rows[c] = append(rows[c], row)
+			all = append(all, row)
+		}
+	}
+	widths := columnWidths(header, all, terminalWidth())
+
+	fmt.Println()
+	writeRow(os.Stdout, header, widths, nil)
+	for i, c := range order {
+		if i > 0 {
+			fmt.Println()
+		}
+		status := grouped[c][0].Status
+		fmt.Println(styles.Bold(fmt.Sprintf("%s (%d)", c, len(grouped[c]))))
+		for _, row := range rows[c] {
+			writeRow(os.Stdout, row, widths, func(col int, s string) string {
+				switch col {
+				case 0:
+					return styles.Path(s)
+				case 2:
+					switch status {
+					case statusSuccess:
+						return styles.Success(s)
+					case statusSkipped:
+						return styles.Muted(s)
+					default:
+						return styles.Error(s)
+					}
+				}
+				return s
+			})
+		}
+	}
+}
+
+// outcomeCategory returns the heading r is grouped under in the results
+// table: whether a pull or fetch brought anything in, why a repository was
+// skipped, or that it failed, for want of credentials or otherwise.
+func outcomeCategory(r result) string {
+	switch r.Status {
+	case statusFailed:
+		if r.Detail == authRequired {
+			return authRequired
+		}
+		return "Failed"
+	case statusSkipped:
+		for _, v := range otherVCSDirs {
+			if r.Detail == otherVCSDetail(v.name) {
+				return otherVCSCategory
+			}
+		}
+		reason := strings.TrimSuffix(strings.TrimPrefix(r.Detail, "Skipped ("), ")")
+		if reason == r.Detail || reason == "" {
+			return "Skipped"
+		}
+		return strings.ToUpper(reason[:1]) + reason[1:] + " " + dash + " skipped"
+	}
+	if r.Operation != "pull" && r.Operation != "fetch" {
+		return "Succeeded"
+	}
+	if r.Detail == "Up to date" || strings.Contains(r.Output, "Already up to date") ||
+		(r.Operation == "fetch" && strings.TrimSpace(r.Output) == "") {
+		return "Already up to date"
+	}
+	return "Updated"
+}
+
+// categoryRank orders the headings of the results table: changes first,
+// then what was left alone, then failures.
+func categoryRank(category string) int {
+	switch {
+	case category == "Updated" || category == "Succeeded":
+		return 0
+	case category == "Already up to date":
+		return 1
+	case category == "Failed" || category == authRequired:
+		return 3
+	}
+	return 2
+}
+
+// writePorcelain writes r as a single tab-separated line. Failures carry
+// the error as their detail. Tabs and newlines within a field are replaced
+// by spaces so that every result stays on one line. The first line of the
+// output is a "# porcelain v<version>" header.
+func writePorcelain(r result) {
+	porcelainHeader.Do(func() {
+		fmt.Fprintf(machineWriter(), "# porcelain v%d\n", formatVersion)
+	})
+	detail := r.Detail
+	if r.Error != "" {
+		detail = r.Error
+	}
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+	fmt.Fprintf(machineWriter(), "%s\t%s\t%s\n", r.Status, clean.Replace(r.Path), clean.Replace(detail))
+}
+
+// lockedWriter serializes writes into a shared buffer.
+type lockedWriter struct {
+	w io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	resultsMu.Lock()
+	defer resultsMu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var retryRemaining bool
+
+// retryOperations are the operations a time budget can leave undone.
+var retryOperations = map[string]func(ctx context.Context, path string) error{
+	"fetch": fetch,
+	"pull":  pull,
+}
+
+// retryCmd represents the retry command
+var retryCmd = &cobra.Command{
+	Use:   "retry --remaining",
+	Short: "Continue a run that its time budget cut short",
+	Long: `Retry --remaining runs the fetch or pull that a --time-budget cut short in
+the repositories it didn't get to. It can be given a --time-budget of its
+own, in which case whatever is still left is kept for the next retry.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if !retryRemaining {
+			return usageErrorf("--remaining is required")
+		}
+
+		r, err := loadRemaining()
+		if err != nil {
+			return err
+		}
+		if r == nil || len(r.Paths) == 0 {
+			fmt.Println("Nothing left to retry")
+			return nil
+		}
+		op, ok := retryOperations[r.Operation]
+		if !ok {
+			return errors.Errorf("unable to retry %s", r.Operation)
+		}
+
+		operation = r.Operation
+		recursive = true
+		started := time.Now()
+		err = walkFound(ctx, func(fn func(path string) error) error {
+			for _, path := range r.Paths {
+				if err := fn(path); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, op)
+		if timeBudget == 0 && ctx.Err() == nil {
+			recordRemaining(nil)
+		}
+		finishRun(time.Since(started))
+		return err
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(retryCmd)
+	describe(retryCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Pull for five minutes, most stale repositories first", "got pull -r --time-budget 5m ~/src"},
+			{"Pull the rest later", "got retry --remaining"},
+		},
+	})
+
+	retryCmd.Flags().BoolVar(&retryRemaining, "remaining", false, "Run the operation in the repositories the last --time-budget left")
+	retryCmd.Flags().DurationVar(&timeBudget, "time-budget", 0, "Stop starting repositories after this long and keep the rest for later")
+}
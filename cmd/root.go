@@ -15,25 +15,97 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+// cfgFiles are the config files given with --config, later ones merged over
+// earlier ones.
+var cfgFiles []string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "got",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "Run git operations across many repositories",
+	Long: `Got runs git operations such as pull, fetch and status in a repository, or
+with -r in every repository beneath a directory, and reports how each went.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+--format replaces the line written for each repository with a Go template,
+executed with .Path, .Name, .Branch, .Operation, .Status (success, skipped
+or failed), .Detail, .Error, .Output and .Duration, and the functions json,
+upper, lower, join and trim:
+
+  got pull -r --format '{{.Name}} {{.Branch}} {{.Status}}' ~/src
+
+Got exits with:
+
+  0    when everything succeeded, or no more than --max-failures repositories failed
+  1    when more repositories failed than --max-failures allows, or got failed otherwise
+  2    when got was asked wrongly: a bad command, flag or argument, or config
+  130  when it was interrupted`,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments have been parsed; errors from here on are
+		// not about how got was invoked.
+		cmd.SilenceUsage = true
+		commandStarted = true
+		operation = cmd.Name()
+		running = registry[strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")]
+		if configErr != nil {
+			return usage(configErr)
+		}
+		if err := mergeLocalConfig(args); err != nil {
+			return usage(err)
+		}
+		if err := applyProfile(cmd); err != nil {
+			return usage(err)
+		}
+		if err := applyTuning(cmd); err != nil {
+			return usage(err)
+		}
+		if err := initStyles(); err != nil {
+			return usage(err)
+		}
+		if err := initLogger(cmd.Flags().Changed("log-level")); err != nil {
+			return usage(err)
+		}
+		if err := openLogFile(os.Args[1:]); err != nil {
+			return err
+		}
+		if err := initOutput(); err != nil {
+			return usage(err)
+		}
+		if err := initReport(); err != nil {
+			return usage(err)
+		}
+		initChangedSince()
+		if err := checkFormatVersion(); err != nil {
+			return usage(err)
+		}
+		if jobs < 1 {
+			return usageErrorf("--jobs must be at least 1")
+		}
+		// The progress line is redrawn in place, which only works on a
+		// terminal; redirected output and build logs get plain lines
+		// instead.
+		showProgress = viper.GetBool("progress")
+		if quiet || ciMode() || !isTerminal(os.Stdout) || !isTerminal(os.Stderr) {
+			showProgress = false
+		}
+		startRunTimeout(cmd)
+		if err := startProfiling(); err != nil {
+			return err
+		}
+		return usage(initRunner())
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
@@ -41,11 +113,21 @@ to quickly create a Cobra application.`,
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Interrupting got cancels the context passed to every command, which stops
+// the walk and kills any git command still running.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := runTimeoutError(RootCmd.ExecuteContext(ctx))
+	interrupted := ctx.Err() != nil
+	cancelRun()
+	stop()
+	closeOutput()
+	closeLogFile(err)
+	stopProfiling()
+	if _, handedOver := errors.Cause(err).(exitStatusError); err != nil && !interrupted && !handedOver {
+		fmt.Fprintln(os.Stderr, "Error:", err)
 	}
+	os.Exit(exitCode(interrupted, err))
 }
 
 func init() {
@@ -55,7 +137,64 @@ func init() {
 	// Cobra supports Persistent Flags, which, if defined here,
 	// will be global for your application.
 
-	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.got.yaml)")
+	RootCmd.PersistentFlags().StringArrayVar(&cfgFiles, "config", nil, "config file (default is $XDG_CONFIG_HOME/got/config.yaml or $HOME/.got.yaml), repeatable with later files taking precedence; a .got.yaml in the target directory or its parents is merged over them")
+	RootCmd.PersistentFlags().String("profile", "", "Use this profile from the config file")
+	viper.BindPFlag("profile", RootCmd.PersistentFlags().Lookup("profile"))
+	RootCmd.PersistentFlags().StringVar(&backend, "backend", "git", "Backend for read-only queries such as status: git or go-git")
+	RootCmd.PersistentFlags().IntVar(&maxDepth, "max-depth", 0, "Limit recursive runs to this many directory levels below the root (0 is unlimited)")
+	RootCmd.PersistentFlags().Bool("hidden", true, "Descend into hidden directories in recursive runs")
+	viper.BindPFlag("hidden", RootCmd.PersistentFlags().Lookup("hidden"))
+	RootCmd.PersistentFlags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinked directories in recursive runs")
+	RootCmd.PersistentFlags().StringSlice("only", nil, "Only run in repositories matching these patterns, by directory name or, with a /, by path (includeList in the config file)")
+	viper.BindPFlag("includeList", RootCmd.PersistentFlags().Lookup("only"))
+	RootCmd.PersistentFlags().StringSlice("origin", nil, "Only run in repositories whose origin matches these host or host/path patterns, such as github.com/acme/* (originList in the config file)")
+	viper.BindPFlag("originList", RootCmd.PersistentFlags().Lookup("origin"))
+	RootCmd.PersistentFlags().BoolVar(&allowProtected, "allow-protected", false, "Let destructive commands delete or rewrite protected branches (protectedBranches in the config file)")
+	RootCmd.PersistentFlags().BoolVar(&includeBare, "include-bare", false, "Include bare repositories, such as mirrors, in recursive runs")
+	RootCmd.PersistentFlags().StringVar(&changedSinceFlag, "changed-since", "", "Only run in repositories whose upstream got new commits within this window (such as 24h or 7d) or since this ref")
+	RootCmd.PersistentFlags().IntVar(&maxFailures, "max-failures", 0, "Exit successfully as long as no more than this many repositories fail")
+	RootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "Stop a recursive run as soon as any repository fails")
+	RootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Write one JSON object per repository and a final summary object")
+	RootCmd.PersistentFlags().BoolVar(&porcelainOutput, "porcelain", false, "Write one stable, tab-separated <status> <path> <detail> line per repository for scripts")
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "output", "", "Output format: text, json, porcelain or csv")
+	RootCmd.PersistentFlags().StringVar(&formatTemplate, "format", "", "Write each repository's result through this Go template, such as '{{.Path}} {{.Branch}} {{.Status}}'")
+	RootCmd.PersistentFlags().StringVar(&outputFileName, "output-file", "", "Write json, porcelain or csv results to this file instead of stdout")
+	RootCmd.PersistentFlags().StringVar(&reportFormat, "report", "", "Also write a report of the run once it is over: junit, for CI, or markdown, for pasting into issues")
+	RootCmd.PersistentFlags().StringVar(&reportFileName, "report-file", "", "File to write the --report to, such as results.xml; a markdown report goes to stdout without one")
+	RootCmd.PersistentFlags().String("metrics-file", "", "Write the statistics of the run to this file in the Prometheus text format, for node_exporter's textfile collector (metricsFile in the config file)")
+	viper.BindPFlag("metricsFile", RootCmd.PersistentFlags().Lookup("metrics-file"))
+	RootCmd.PersistentFlags().IntVar(&formatVersion, "format-version", formatVersionLatest, "Version of the --json and --porcelain formats to write")
+	RootCmd.PersistentFlags().String("log-file", "", "Append an unstyled, timestamped log of the run, including all git output, to this file")
+	viper.BindPFlag("logFile", RootCmd.PersistentFlags().Lookup("log-file"))
+	RootCmd.PersistentFlags().String("webhook", "", "POST the summary of a recursive run to this URL, as a Slack or Teams message or generic JSON (webhook in the config file)")
+	viper.BindPFlag("webhook", RootCmd.PersistentFlags().Lookup("webhook"))
+	RootCmd.PersistentFlags().StringVar(&logLevelName, "log-level", "info", "Least severe messages to log: debug, info, warn or error")
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text, or json for one object per message")
+	RootCmd.PersistentFlags().Bool("relative", false, "Show repositories by their path relative to the directory being walked")
+	viper.BindPFlag("relative", RootCmd.PersistentFlags().Lookup("relative"))
+	RootCmd.PersistentFlags().Bool("ascii", false, "Draw icons and the progress spinner in plain ASCII")
+	viper.BindPFlag("ascii", RootCmd.PersistentFlags().Lookup("ascii"))
+	RootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Write plain text without colors (also set by NO_COLOR)")
+	RootCmd.PersistentFlags().Bool("ci", false, "Write plain lines for a build log, with no colors or progress line (on when CI is set, ciMode in the config file)")
+	viper.BindPFlag("ciMode", RootCmd.PersistentFlags().Lookup("ci"))
+	RootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Show each git command as it runs; -vv shows all git output too")
+	RootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only report failures and the summary of the run")
+	RootCmd.PersistentFlags().IntVarP(&jobs, "jobs", "j", 1, "Number of repositories to work on at once in recursive runs")
+	RootCmd.PersistentFlags().DurationVar(&repoTimeout, "timeout", 0, "Fail a repository whose operation takes longer than this (0 is unlimited)")
+	RootCmd.PersistentFlags().DurationVar(&totalTimeout, "total-timeout", 0, "Stop the whole run after this long (0 is unlimited)")
+	RootCmd.PersistentFlags().BoolP("progress", "p", false, "Show a progress line during recursive runs (progress in the config file)")
+	viper.BindPFlag("progress", RootCmd.PersistentFlags().Lookup("progress"))
+	viper.SetDefault("spinner", true)
+	RootCmd.PersistentFlags().BoolVar(&streamResults, "stream", false, "With --progress, print each repository's result as soon as it completes")
+	RootCmd.PersistentFlags().BoolVar(&noCount, "no-count", false, "Show a spinner and running totals instead of a percentage")
+
+	RootCmd.PersistentFlags().StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+	RootCmd.PersistentFlags().StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+	RootCmd.PersistentFlags().StringVar(&traceFile, "trace", "", "Write an execution trace to this file")
+	RootCmd.PersistentFlags().MarkHidden("cpuprofile")
+	RootCmd.PersistentFlags().MarkHidden("memprofile")
+	RootCmd.PersistentFlags().MarkHidden("trace")
+
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -63,16 +202,29 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	if cfgFile != "" { // enable ability to specify config file via flag
-		viper.SetConfigFile(cfgFile)
+	if len(cfgFiles) > 0 { // enable ability to specify config file via flag
+		viper.SetConfigFile(cfgFiles[0])
+	} else if _, err := os.Stat(xdgConfigFile()); err == nil {
+		viper.SetConfigFile(xdgConfigFile())
+	} else {
+		// $HOME/.got.yaml predates the XDG location and still works.
+		viper.SetConfigName(".got")  // name of config file (without extension)
+		viper.AddConfigPath("$HOME") // adding home directory as first search path
 	}
-
-	viper.SetConfigName(".got")  // name of config file (without extension)
-	viper.AddConfigPath("$HOME") // adding home directory as first search path
-	viper.AutomaticEnv()         // read in environment variables that match
+	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
+	seen := map[string]bool{}
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		main := absPath(viper.ConfigFileUsed())
+		configFiles = append(configFiles, main)
+		seen[main] = true
+		if len(viper.GetStringSlice("configs")) > 0 {
+			configErr = layerConfig(main, seen, false)
+		}
+	}
+	for i := 1; i < len(cfgFiles) && configErr == nil; i++ {
+		configErr = layerConfig(cfgFiles[i], seen, true)
 	}
 }
@@ -0,0 +1,58 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var backend string
+
+// commandRunner runs a git subcommand in the repository at path, writing
+// its output to out. A nil out discards the output. Implementations must
+// give up promptly once ctx is cancelled.
+type commandRunner interface {
+	Run(ctx context.Context, path string, out io.Writer, args ...string) error
+}
+
+// runner is the commandRunner used by all commands. It is chosen by
+// --backend once flags have been parsed.
+var runner commandRunner = execRunner{}
+
+// execRunner runs git as an external process.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, path string, out io.Writer, args ...string) error {
+	cmd := gitCommand(ctx, path, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// initRunner selects the runner named by --backend.
+func initRunner() error {
+	switch backend {
+	case "", "git":
+		runner = adaptiveRunner{next: execRunner{}}
+	case "go-git":
+		runner = goGitRunner{fallback: adaptiveRunner{next: execRunner{}}}
+	default:
+		return errors.Errorf("unknown backend [%s], expected git or go-git", backend)
+	}
+	return nil
+}
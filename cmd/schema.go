@@ -0,0 +1,456 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v3"
+)
+
+// settingKind is the type of value a config key takes.
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindBool
+	kindInt
+	kindList    // a list of elem
+	kindStrings // a string or a list of strings
+	kindMap     // any keys, each with an elem
+	kindObject  // the keys of fields
+)
+
+// setting describes a config key for config validate.
+type setting struct {
+	kind   settingKind
+	elem   *setting
+	fields map[string]*setting
+
+	// check, when set, vets a scalar value further.
+	check func(value string) error
+
+	// orList, when set, is what a list in place of the value is validated
+	// against.
+	orList *setting
+}
+
+func stringSetting() *setting { return &setting{kind: kindString} }
+func boolSetting() *setting   { return &setting{kind: kindBool} }
+func intSetting() *setting    { return &setting{kind: kindInt} }
+
+// durationSetting is a string such as 90s or 5m.
+func durationSetting() *setting {
+	return &setting{kind: kindString, check: func(value string) error {
+		_, err := time.ParseDuration(value)
+		return err
+	}}
+}
+
+func listSetting(elem *setting) *setting { return &setting{kind: kindList, elem: elem} }
+func mapSetting(elem *setting) *setting  { return &setting{kind: kindMap, elem: elem} }
+
+func objectSetting(fields map[string]*setting) *setting {
+	return &setting{kind: kindObject, fields: fields}
+}
+
+// configSchema returns the keys the config file can have. Each key that
+// got reads belongs here, or config validate reports it as unknown.
+func configSchema() *setting {
+
+	color := &setting{kind: kindString, check: func(value string) error {
+		_, err := colorCode(value)
+		return err
+	}}
+
+	fields := map[string]*setting{
+		"skip":              listSetting(stringSetting()),
+		"includeList":       listSetting(stringSetting()),
+		"originList":        listSetting(stringSetting()),
+		"protectedBranches": listSetting(stringSetting()),
+		"hidden":            boolSetting(),
+		"relative":          boolSetting(),
+		"ascii":             boolSetting(),
+		"progress":          boolSetting(),
+		"spinner":           boolSetting(),
+		"ciMode":            boolSetting(),
+		"logFile":           stringSetting(),
+		"webhook":           stringSetting(),
+		"metricsFile":       stringSetting(),
+		"forges": listSetting(objectSetting(map[string]*setting{
+			"host": stringSetting(),
+			"type": {kind: kindString, check: func(value string) error {
+				if value != "github" && value != "gitlab" {
+					return errors.Errorf("expected github or gitlab, not [%s]", value)
+				}
+				return nil
+			}},
+			"api": stringSetting(),
+		})),
+		"hooks": objectSetting(map[string]*setting{
+			hookPreRepo:  stringSetting(),
+			hookPostRepo: stringSetting(),
+			hookPostRun:  stringSetting(),
+		}),
+		"webhookFormat": {kind: kindString, check: func(value string) error {
+			if value != "generic" && value != "slack" && value != "teams" {
+				return errors.Errorf("expected generic, slack or teams, not [%s]", value)
+			}
+			return nil
+		}},
+		"gitPath":             stringSetting(),
+		"gitGlobalArgs":       {kind: kindStrings},
+		"defaultPath":         stringSetting(),
+		"configs":             listSetting(stringSetting()),
+		"trustedLocalConfigs": listSetting(stringSetting()),
+		"maxDepth":            intSetting(),
+		"jobs":                intSetting(),
+		"timeout":             durationSetting(),
+		"totalTimeout":        durationSetting(),
+		"profile":             stringSetting(),
+		"names":               mapSetting(stringSetting()),
+		"groups": mapSetting(&setting{kind: kindObject, orList: listSetting(stringSetting()), fields: map[string]*setting{
+			"paths":       listSetting(stringSetting()),
+			"skip":        listSetting(stringSetting()),
+			"hidden":      boolSetting(),
+			"maxDepth":    intSetting(),
+			"maxFailures": intSetting(),
+			"failFast":    boolSetting(),
+			"ifBehind":    boolSetting(),
+			"jobs":        intSetting(),
+			"hooks": objectSetting(map[string]*setting{
+				hookPreRepo:  stringSetting(),
+				hookPostRepo: stringSetting(),
+				hookPostRun:  stringSetting(),
+			}),
+		}}),
+		"maintenance": mapSetting(listSetting(stringSetting())),
+		"manifest": listSetting(objectSetting(map[string]*setting{
+			"path":   stringSetting(),
+			"remote": stringSetting(),
+			"branch": stringSetting(),
+		})),
+		"ci": objectSetting(map[string]*setting{
+			"command": stringSetting(),
+		}),
+		"ssh": objectSetting(map[string]*setting{
+			"fingerprints": mapSetting(&setting{kind: kindStrings}),
+		}),
+		"theme": objectSetting(map[string]*setting{
+			"name": {kind: kindString, check: func(value string) error {
+				if _, ok := themes[value]; !ok {
+					return errors.Errorf("unknown theme [%s], expected dark or light", value)
+				}
+				return nil
+			}},
+			"colors": objectSetting(map[string]*setting{
+				"primary": color,
+				"success": color,
+				"error":   color,
+				"warn":    color,
+				"muted":   color,
+			}),
+			"icons": objectSetting(map[string]*setting{
+				"success": stringSetting(),
+				"error":   stringSetting(),
+				"skipped": stringSetting(),
+				"waiting": stringSetting(),
+			}),
+		}),
+	}
+
+	// An override can turn off any command.
+	override := map[string]*setting{
+		"path":   stringSetting(),
+		"branch": stringSetting(),
+	}
+	for name := range registry {
+		if !strings.Contains(name, " ") {
+			override[name] = boolSetting()
+		}
+	}
+	override["pull"] = &setting{kind: kindString, check: func(value string) error {
+		if _, ok := pullStrategies[value]; !ok && value != "true" && value != "false" {
+			return errors.Errorf("expected true, false, rebase, ff-only or merge, not [%s]", value)
+		}
+		return nil
+	}}
+	fields["overrides"] = listSetting(objectSetting(override))
+
+	// A profile can set anything the config file can, a root, and the
+	// flags of any command.
+	profile := map[string]*setting{"root": stringSetting()}
+	for key, s := range flagSettings(RootCmd) {
+		profile[key] = s
+	}
+	for key, s := range fields {
+		if key != "profile" {
+			profile[key] = s
+		}
+	}
+	fields["profiles"] = mapSetting(objectSetting(profile))
+
+	return objectSetting(fields)
+}
+
+// flagSettings returns a setting for each flag of cmd and its subcommands,
+// keyed by the flag's name in camel case.
+func flagSettings(cmd *cobra.Command) map[string]*setting {
+
+	settings := map[string]*setting{}
+	add := func(f *pflag.Flag) {
+		if f.Name == "config" || f.Name == "profile" || f.Name == "help" {
+			return
+		}
+		s := stringSetting()
+		switch f.Value.Type() {
+		case "bool":
+			s = boolSetting()
+		case "int", "count":
+			s = intSetting()
+		case "duration":
+			s = durationSetting()
+		case "stringSlice", "stringArray":
+			s = &setting{kind: kindStrings}
+		}
+		settings[camelCase(f.Name)] = s
+	}
+
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		c.PersistentFlags().VisitAll(add)
+		c.Flags().VisitAll(add)
+		for _, sub := range c.Commands() {
+			visit(sub)
+		}
+	}
+	visit(cmd)
+	return settings
+}
+
+// camelCase turns a flag name such as max-depth into maxDepth.
+func camelCase(name string) string {
+	parts := strings.Split(name, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// configProblem is something wrong with a config file, at a line of it.
+type configProblem struct {
+	line, column int
+	msg          string
+}
+
+// validateConfigFile checks the YAML config file name against the schema
+// and returns its problems in the order they appear.
+func validateConfigFile(name string) ([]configProblem, error) {
+
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read config file [%s]", name)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// The parser's messages carry their own line numbers.
+		return []configProblem{{msg: strings.TrimPrefix(err.Error(), "yaml: ")}}, nil
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	var problems []configProblem
+	validateNode(doc.Content[0], configSchema(), "", &problems)
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].line != problems[j].line {
+			return problems[i].line < problems[j].line
+		}
+		return problems[i].column < problems[j].column
+	})
+	return problems, nil
+}
+
+// validateNode checks n, the value of key, against s, adding what is wrong
+// with it to problems.
+func validateNode(n *yaml.Node, s *setting, key string, problems *[]configProblem) {
+
+	report := func(n *yaml.Node, format string, args ...interface{}) {
+		*problems = append(*problems, configProblem{line: n.Line, column: n.Column, msg: fmt.Sprintf(format, args...)})
+	}
+	name := key
+	if name == "" {
+		name = "the config file"
+	}
+
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.SequenceNode && s.orList != nil {
+		s = s.orList
+	}
+	// A key with no value leaves the setting unset.
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+
+	switch s.kind {
+	case kindString:
+		if n.Kind != yaml.ScalarNode {
+			report(n, "%s must be a string", name)
+			return
+		}
+	case kindBool:
+		if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" {
+			report(n, "%s must be true or false, not %s", name, describeNode(n))
+			return
+		}
+	case kindInt:
+		if n.Kind != yaml.ScalarNode || n.Tag != "!!int" {
+			report(n, "%s must be a whole number, not %s", name, describeNode(n))
+			return
+		}
+	case kindStrings:
+		if n.Kind == yaml.ScalarNode {
+			break
+		}
+		fallthrough
+	case kindList:
+		if n.Kind != yaml.SequenceNode {
+			report(n, "%s must be a list, not %s", name, describeNode(n))
+			return
+		}
+		elem := s.elem
+		if elem == nil {
+			elem = stringSetting()
+		}
+		for _, item := range n.Content {
+			validateNode(item, elem, key+"[]", problems)
+		}
+		return
+	case kindMap, kindObject:
+		if n.Kind != yaml.MappingNode {
+			report(n, "%s must be a set of keys, not %s", name, describeNode(n))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			path := k.Value
+			if key != "" {
+				path = key + "." + k.Value
+			}
+			if s.kind == kindMap {
+				validateNode(v, s.elem, path, problems)
+				continue
+			}
+			field, ok := lookupSetting(s.fields, k.Value)
+			if !ok {
+				if suggestion := closestKey(s.fields, k.Value); suggestion != "" {
+					report(k, "unknown key %s, did you mean %s?", path, suggestion)
+				} else {
+					report(k, "unknown key %s", path)
+				}
+				continue
+			}
+			validateNode(v, field, path, problems)
+		}
+		return
+	}
+
+	if s.check != nil {
+		if err := s.check(n.Value); err != nil {
+			report(n, "%s: %s", name, err)
+		}
+	}
+}
+
+// lookupSetting finds key in fields the way viper does, ignoring case.
+func lookupSetting(fields map[string]*setting, key string) (*setting, bool) {
+	for name, s := range fields {
+		if strings.EqualFold(name, key) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// closestKey returns the key of fields nearest to key, for a likely typo,
+// or an empty string when none is close.
+func closestKey(fields map[string]*setting, key string) string {
+
+	// Up to one edit in two characters still reads as the same word.
+	limit := len(key) / 2
+	if limit < 1 {
+		limit = 1
+	}
+
+	best, bestDistance := "", limit+1
+	for name := range fields {
+		d := editDistance(strings.ToLower(name), strings.ToLower(key))
+		if d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// describeNode names the kind of value n is, for problem messages.
+func describeNode(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.MappingNode:
+		return "a set of keys"
+	}
+	return fmt.Sprintf("%q", n.Value)
+}
@@ -0,0 +1,134 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateConfigFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"comments only", "# nothing yet\n", nil},
+		{
+			"valid",
+			`jobs: 8
+hidden: true
+timeout: 90s
+gitGlobalArgs: -c core.quotepath=off
+skip: [node_modules, "*.bak"]
+groups:
+  work: [~/src/work]
+  oss:
+    paths: [~/src/oss]
+    jobs: 2
+    hooks:
+      preRepo: make check
+maintenance:
+  weekly: [gc, prune]
+overrides:
+  - path: ~/src/legacy
+    pull: rebase
+    fetch: false
+theme:
+  name: dark
+  colors:
+    primary: "#5f87ff"
+profiles:
+  ci:
+    jobs: 16
+    json: true
+`,
+			nil,
+		},
+		{
+			"unknown keys",
+			"jbos: 4\nlogfile: got.log\nfrobnicate: true\n",
+			[]string{
+				"1:1: unknown key jbos, did you mean jobs?",
+				"3:1: unknown key frobnicate",
+			},
+		},
+		{
+			"wrong types",
+			"jobs: four\nhidden: yes please\nskip: node_modules\ngroups:\n  work: 3\nhooks: make\n",
+			[]string{
+				`1:7: jobs must be a whole number, not "four"`,
+				`2:9: hidden must be true or false, not "yes please"`,
+				`3:7: skip must be a list, not "node_modules"`,
+				`5:9: groups.work must be a set of keys, not "3"`,
+				`6:8: hooks must be a set of keys, not "make"`,
+			},
+		},
+		{
+			"values",
+			"timeout: soon\nwebhookFormat: discord\nforges:\n  - host: git.example.com\n    type: gitea\ntheme:\n  name: solarized\n",
+			[]string{
+				`1:10: timeout: time: invalid duration "soon"`,
+				"2:16: webhookFormat: expected generic, slack or teams, not [discord]",
+				"5:11: forges[].type: expected github or gitlab, not [gitea]",
+				"7:9: theme.name: unknown theme [solarized], expected dark or light",
+			},
+		},
+		{
+			"nested",
+			"overrides:\n  - path: ~/src/a\n    pull: sometimes\n    pulll: true\nprofiles:\n  ci:\n    jobs: [1]\n",
+			[]string{
+				"3:11: overrides[].pull: expected true, false, rebase, ff-only or merge, not [sometimes]",
+				"4:5: unknown key overrides[].pulll, did you mean pull?",
+				"7:11: profiles.ci.jobs must be a whole number, not a list",
+			},
+		},
+		{"unset", "jobs:\nskip: ~\n", nil},
+		{"not a mapping", "- jobs\n", []string{"1:1: the config file must be a set of keys, not a list"}},
+		{"not yaml", "jobs: [\n", []string{"0:0: line 1: did not find expected node content"}},
+	}
+
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, fmt.Sprintf("config%d.yaml", i))
+			if err := os.WriteFile(name, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			problems, err := validateConfigFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range problems {
+				got = append(got, fmt.Sprintf("%d:%d: %s", p.line, p.column, p.msg))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateConfigFile() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigFileMissing(t *testing.T) {
+	if _, err := validateConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("validateConfigFile() of a missing file returned no error")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// signingFailed matches git's output when the signing program gave up,
+// which on its own only says that gpg failed.
+var signingFailed = regexp.MustCompile(`(?i)gpg failed to sign|failed to write commit object|unable to sign the tag|error: (gpg|ssh-keygen|gpgsm)`)
+
+// signs reports whether git signs commits, or tags with kind tag, in the
+// repository at path: because sign says to, or its configuration does.
+func signs(ctx context.Context, path, kind string, sign bool) bool {
+	if sign {
+		return true
+	}
+	out, _ := gitOutput(ctx, path, "config", "--type=bool", "--get", kind+".gpgSign")
+	return out == "true"
+}
+
+// checkSigning returns what is missing for the repository at path to sign
+// with, if anything: a signing key where the format needs one, or the
+// program that signs.
+func checkSigning(ctx context.Context, path string) error {
+
+	format, _ := gitOutput(ctx, path, "config", "--get", "gpg.format")
+	if format == "" {
+		format = "openpgp"
+	}
+	key, _ := gitOutput(ctx, path, "config", "--get", "user.signingKey")
+
+	program, _ := gitOutput(ctx, path, "config", "--get", "gpg."+format+".program")
+	if program == "" && format == "openpgp" {
+		program, _ = gitOutput(ctx, path, "config", "--get", "gpg.program")
+	}
+	if program == "" {
+		program = map[string]string{"openpgp": "gpg", "ssh": "ssh-keygen", "x509": "gpgsm"}[format]
+	}
+	if program == "" {
+		return errors.Errorf("signing is not configured: unknown gpg.format [%s], expected openpgp, ssh or x509", format)
+	}
+
+	if _, err := exec.LookPath(expandHome(program)); err != nil {
+		return errors.Errorf("signing is not configured: %s, which signs with gpg.format %s, is not installed", program, format)
+	}
+	if format == "ssh" {
+		if key == "" {
+			return errors.New("signing is not configured: gpg.format is ssh and user.signingKey is not set; set it with git config user.signingKey ~/.ssh/id_ed25519.pub")
+		}
+		// A key can be given as a file or literally, as key::ssh-ed25519 ...
+		if !strings.HasPrefix(key, "key::") && !strings.HasPrefix(key, "ssh-") {
+			if _, err := os.Stat(expandHome(key)); err != nil {
+				return errors.Errorf("signing is not configured: the user.signingKey [%s] does not exist", key)
+			}
+		}
+	}
+	return nil
+}
+
+// signingError turns the failure of a command that signs, whose output is
+// out, into something more telling than gpg's own message.
+func signingError(err error, out []byte) error {
+	if !signingFailed.Match(out) {
+		return err
+	}
+	// The signing program's own complaint says more than git's.
+	text := strings.TrimSpace(string(out))
+	line := lastLine(text)
+	for _, l := range strings.Split(text, "\n") {
+		if strings.HasPrefix(l, "gpg: ") || strings.HasPrefix(l, "gpgsm: ") {
+			line = l
+			break
+		}
+	}
+	msg := "signing failed"
+	if line != "" {
+		msg += " (" + line + ")"
+	}
+	return errors.New(msg + "; check that the signing key is available and unlocked, and for gpg that gpg-agent is running with GPG_TTY set to $(tty)")
+}
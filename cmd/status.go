@@ -15,39 +15,56 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"io"
+	"strings"
 
+	"github.com/id9051/got/internal/git"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+var (
+	statusPaths []string
+	statusFull  bool
+	statusShort bool
+)
+
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
-	Use:   "status",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+	Use:   "status directory",
+	Short: "Show the status of the repositories beneath a directory",
+	Long: `Status runs git status in a repository, or with -r in every repository
+beneath a directory. A single repository's status is shown in full; with -r
+each repository gets a one-line summary of its branch, how far it is ahead
+of or behind its upstream, and how many files are staged, modified and
+untracked, unless --full asks for git's own output. --short shows git's short
+format instead, the branch line as the result and the changed files
+beneath it. With --paths the status is limited to those pathspecs within
+each repository.`,
+	ValidArgsFunction: completeDirectory,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return errors.New("directory argument is required")
-		}
-		if recursive {
-			return statusWalk(args[0])
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
 		}
-		return status(args[0])
+		return runOperation(ctx, args[0], status)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(statusCmd)
+	describe(statusCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Show the status of every repository beneath ~/src", "got status -r ~/src"},
+			{"Limit the status to the docs directory of each repository", "got status -r --paths docs ~/src"},
+			{"Show git's short format for every repository", "got status -r --short ~/src"},
+		},
+	})
 
 	// Here you will define your flags and configuration settings.
 
@@ -59,45 +76,98 @@ func init() {
 	// is called directly, e.g.:
 	// statusCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	statusCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively check status of subdirectories listed")
+	statusCmd.Flags().StringSliceVar(&statusPaths, "paths", nil, "Limit status to these pathspecs within each repository")
+	statusCmd.Flags().BoolVar(&statusFull, "full", false, "With -r, show the full git status of each repository instead of a summary")
+	statusCmd.Flags().BoolVar(&statusShort, "short", false, "Show git's short status format (git status -sb)")
 }
 
-func status(path string) error {
+func status(ctx context.Context, path string) error {
 
-	_, err := os.Stat(filepath.Join(path, ".git"))
-	if err != nil {
+	if !isRepository(path) {
 		if recursive {
 			return nil
 		}
-		return errors.Wrapf(err, "[%s] is not a git repository", path)
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	if git.IsBare(path) {
+		repoSkipped(path, "Skipped (bare repository)")
+		return nil
 	}
 
-	statusCmd := exec.Command("git", fmt.Sprintf("--work-tree=%s", path), fmt.Sprintf("--git-dir=%s", filepath.Join(path, ".git")), "status")
-	statusCmd.Stdout = os.Stdout
-	statusCmd.Stderr = os.Stderr
+	if statusShort {
+		return statusShortFormat(ctx, path)
+	}
+	if recursive && !statusFull {
+		return statusSummary(ctx, path)
+	}
 
-	if err := statusCmd.Run(); err != nil {
-		log.Printf("[%s]: ERROR %v\n", path, err)
+	args := []string{"status"}
+	if len(statusPaths) > 0 {
+		args = append(append(args, "--"), statusPaths...)
+	}
+
+	if err := runner.Run(ctx, path, repoOutput(path), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, err)
 	} else {
-		log.Printf("[%s]:  Success\n", path)
+		repoSucceeded(path, "Success")
 	}
 
 	return nil
 }
 
-func statusWalk(path string) error {
+// statusSummary reports the status of the repository at path as a single
+// line parsed from git status --porcelain=v2.
+func statusSummary(ctx context.Context, path string) error {
 
-	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+	args := []string{"status", "--porcelain=v2", "--branch"}
+	if len(statusPaths) > 0 {
+		args = append(append(args, "--"), statusPaths...)
+	}
 
-		if err != nil {
-			return errors.Wrapf(err, "error walking filepath [%s]", path)
+	var out bytes.Buffer
+	if err := runner.Run(ctx, path, io.MultiWriter(&out, repoCapture(path)), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		repoFailed(path, err)
+		return nil
+	}
 
-		if !info.IsDir() {
-			return nil
-		} else if filepath.Base(path) == ".git" {
-			return filepath.SkipDir
+	repoSucceeded(path, "%s", git.ParseStatus(out.String()))
+	return nil
+}
+
+// statusShortFormat reports the status of the repository at path in git's
+// short format: the branch line becomes the result and the changed files,
+// if any, are shown indented beneath it.
+func statusShortFormat(ctx context.Context, path string) error {
+
+	args := []string{"status", "-sb"}
+	if len(statusPaths) > 0 {
+		args = append(append(args, "--"), statusPaths...)
+	}
+
+	var out bytes.Buffer
+	if err := runner.Run(ctx, path, &out, args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		repoCapture(path).Write(out.Bytes())
+		repoFailed(path, err)
+		return nil
+	}
 
-		return status(path)
-	})
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	branch := strings.TrimPrefix(lines[0], "## ")
+	var files bytes.Buffer
+	for _, line := range lines[1:] {
+		fmt.Fprintf(&files, "  %s\n", line)
+	}
+
+	repoSucceededWithOutput(path, files.Bytes(), "%s", branch)
+	return nil
 }
@@ -0,0 +1,91 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/id9051/got/internal/git"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want git.Status
+	}{
+		{"empty", "", git.Status{}},
+		{
+			"clean with upstream",
+			"# branch.oid 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n# branch.head main\n# branch.upstream origin/main\n# branch.ab +0 -0\n",
+			git.Status{Branch: "main", Upstream: "origin/main"},
+		},
+		{
+			"ahead and behind",
+			"# branch.head feature/login\n# branch.upstream origin/feature/login\n# branch.ab +2 -1\n",
+			git.Status{Branch: "feature/login", Upstream: "origin/feature/login", Ahead: 2, Behind: 1},
+		},
+		{
+			"no upstream",
+			"# branch.oid 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n# branch.head main\n",
+			git.Status{Branch: "main"},
+		},
+		{
+			"detached",
+			"# branch.oid 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n# branch.head (detached)\n",
+			git.Status{Branch: "4b825dc", Detached: true},
+		},
+		{
+			"detached at a short oid",
+			"# branch.oid abc\n# branch.head (detached)\n",
+			git.Status{Branch: "abc", Detached: true},
+		},
+		{
+			"initial commit",
+			"# branch.oid (initial)\n# branch.head main\n? README.md\n",
+			git.Status{Branch: "main", Untracked: 1},
+		},
+		{
+			"changes",
+			"# branch.head main\n" +
+				"1 M. N... 100644 100644 100644 aaa bbb staged.go\n" +
+				"1 .M N... 100644 100644 100644 aaa bbb modified.go\n" +
+				"1 MM N... 100644 100644 100644 aaa bbb both.go\n" +
+				"2 R. N... 100644 100644 100644 aaa bbb R100 new.go\told.go\n" +
+				"u UU N... 100644 100644 100644 100644 aaa bbb ccc conflict.go\n" +
+				"? untracked.go\n" +
+				"? notes/todo.txt\n",
+			git.Status{Branch: "main", Staged: 3, Modified: 2, Untracked: 2, Conflicted: 1},
+		},
+		{
+			"windows line endings",
+			"# branch.head main\r\n# branch.ab +1 -0\r\n? a.txt\r\n",
+			git.Status{Branch: "main", Ahead: 1, Untracked: 1},
+		},
+		{
+			"unknown and short lines",
+			"# branch.head main\n# branch.future x\n#\n! ignored.log\nx\n",
+			git.Status{Branch: "main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := git.ParseStatus(tt.out); got != tt.want {
+				t.Errorf("ParseStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Styler renders the pieces of got's console output. All output goes
+// through the package level styles so it can be swapped as a whole, for
+// example for plainStyler when color is unwanted.
+type Styler interface {
+	Success(s string) string
+	Error(s string) string
+	Warning(s string) string
+	Muted(s string) string
+	Path(s string) string
+	Bold(s string) string
+}
+
+var styles Styler = defaultStyler()
+
+// noColor is set by --no-color.
+var noColor bool
+
+// defaultStyler picks plain text when the terminal can't show color, when
+// output is redirected to a file or pipe, or when the user has asked for
+// none with NO_COLOR (https://no-color.org).
+func defaultStyler() Styler {
+	if os.Getenv("TERM") == "dumb" || os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stdout) {
+		return plainStyler{}
+	}
+	return ansiStyler{theme: themes["dark"]}
+}
+
+// ciMode reports whether got is writing to a build log: with --ci, or ciMode
+// in the config file, or else when the CI environment variable that CI
+// services set is there. The log then gets plain, timestamped lines with no
+// colors, progress line or spinner redrawn in place.
+func ciMode() bool {
+	if viper.IsSet("ciMode") {
+		return viper.GetBool("ciMode")
+	}
+	ci := strings.ToLower(os.Getenv("CI"))
+	return ci != "" && ci != "false" && ci != "0"
+}
+
+// initStyles applies --ascii, the theme from the config file and
+// --no-color, or --ci, once flags have been parsed.
+func initStyles() error {
+	if viper.GetBool("ascii") {
+		useASCII()
+	}
+	if err := applyTheme(); err != nil {
+		return err
+	}
+	if noColor || ciMode() {
+		styles = plainStyler{}
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useASCII swaps the Unicode icons, spinner and marks for plain ASCII, for
+// terminals and log systems that render them poorly. Icons set by a theme
+// still take their place.
+func useASCII() {
+	iconSuccess, iconError, iconSkipped, iconWaiting = "+", "x", "-", "~"
+	spinnerFrames = []string{"|", "/", "-", "\\"}
+	ellipsis = "~"
+	dash = "-"
+}
+
+// theme holds the SGR parameters ansiStyler colors each piece with.
+type theme struct {
+	primary string
+	success string
+	error   string
+	warning string
+	muted   string
+}
+
+// themes are the built-in themes: dark, the default, for dark backgrounds
+// and light for light ones.
+var themes = map[string]theme{
+	"dark":  {primary: "36", success: "32", error: "31", warning: "33", muted: "90"},
+	"light": {primary: "34", success: "38;5;28", error: "38;5;124", warning: "38;5;130", muted: "38;5;242"},
+}
+
+// colorNames are the colors a theme can name, besides 256-color indexes
+// and #rrggbb.
+var colorNames = map[string]string{
+	"black": "30", "red": "31", "green": "32", "yellow": "33",
+	"blue": "34", "magenta": "35", "cyan": "36", "white": "37", "gray": "90",
+	"bright-red": "91", "bright-green": "92", "bright-yellow": "93",
+	"bright-blue": "94", "bright-magenta": "95", "bright-cyan": "96", "bright-white": "97",
+}
+
+// applyTheme sets the colors and icons from the theme section of the
+// config file:
+//
+//	theme:
+//	  name: light
+//	  colors:
+//	    primary: blue
+//	    success: "#2e7d32"
+//	    muted: 244
+//	  icons:
+//	    success: "+"
+//	    error: "!"
+//
+// name picks a built-in theme, dark or light, that colors override one by
+// one. Colors are names such as red or bright-blue, 256-color indexes or
+// #rrggbb. Icons replace the success, error, skipped and waiting marks.
+func applyTheme() error {
+
+	name := viper.GetString("theme.name")
+	if name == "" {
+		name = "dark"
+	}
+	t, ok := themes[name]
+	if !ok {
+		return errors.Errorf("unknown theme [%s], expected dark or light", name)
+	}
+
+	for key, field := range map[string]*string{
+		"primary": &t.primary,
+		"success": &t.success,
+		"error":   &t.error,
+		"warn":    &t.warning,
+		"muted":   &t.muted,
+	} {
+		value := viper.GetString("theme.colors." + key)
+		if value == "" {
+			continue
+		}
+		code, err := colorCode(value)
+		if err != nil {
+			return errors.Wrapf(err, "theme color %s", key)
+		}
+		*field = code
+	}
+
+	for key, icon := range map[string]*string{
+		"success": &iconSuccess,
+		"error":   &iconError,
+		"skipped": &iconSkipped,
+		"waiting": &iconWaiting,
+	} {
+		if value := viper.GetString("theme.icons." + key); value != "" {
+			*icon = value
+		}
+	}
+
+	if a, ok := styles.(ansiStyler); ok {
+		a.theme = t
+		styles = a
+	}
+	return nil
+}
+
+// colorCode returns the SGR parameters of a color given by name, 256-color
+// index or #rrggbb.
+func colorCode(color string) (string, error) {
+
+	color = strings.ToLower(strings.TrimSpace(color))
+	if code, ok := colorNames[color]; ok {
+		return code, nil
+	}
+	if n, err := strconv.Atoi(color); err == nil && n >= 0 && n <= 255 {
+		return "38;5;" + color, nil
+	}
+	if strings.HasPrefix(color, "#") && len(color) == 7 {
+		if rgb, err := strconv.ParseUint(color[1:], 16, 32); err == nil {
+			return "38;2;" + strconv.Itoa(int(rgb>>16)) + ";" + strconv.Itoa(int(rgb>>8&0xff)) + ";" + strconv.Itoa(int(rgb&0xff)), nil
+		}
+	}
+	return "", errors.Errorf("invalid color [%s], expected a name, a 256-color index or #rrggbb", color)
+}
+
+// ansiStyler colors output with ANSI escape sequences in the colors of its
+// theme.
+type ansiStyler struct {
+	theme theme
+}
+
+func (a ansiStyler) Success(s string) string { return sgr(a.theme.success, s) }
+func (a ansiStyler) Error(s string) string   { return sgr(a.theme.error, s) }
+func (a ansiStyler) Warning(s string) string { return sgr(a.theme.warning, s) }
+func (a ansiStyler) Muted(s string) string   { return sgr(a.theme.muted, s) }
+func (a ansiStyler) Path(s string) string    { return sgr(a.theme.primary, s) }
+func (ansiStyler) Bold(s string) string      { return sgr("1", s) }
+
+func sgr(code, s string) string {
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+// plainStyler leaves text untouched.
+type plainStyler struct{}
+
+func (plainStyler) Success(s string) string { return s }
+func (plainStyler) Error(s string) string   { return s }
+func (plainStyler) Warning(s string) string { return s }
+func (plainStyler) Muted(s string) string   { return s }
+func (plainStyler) Path(s string) string    { return s }
+func (plainStyler) Bold(s string) string    { return s }
@@ -0,0 +1,134 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// withStyler sets styles for the rest of the test.
+func withStyler(t *testing.T, s Styler) {
+	saved := styles
+	styles = s
+	t.Cleanup(func() { styles = saved })
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		done <- out
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+func TestPlainStyler(t *testing.T) {
+	var s plainStyler
+	for _, tt := range []struct {
+		name string
+		got  string
+	}{
+		{"Success", s.Success("✓ Up to date")},
+		{"Error", s.Error("✓ Up to date")},
+		{"Warning", s.Warning("✓ Up to date")},
+		{"Muted", s.Muted("✓ Up to date")},
+		{"Path", s.Path("✓ Up to date")},
+		{"Bold", s.Bold("✓ Up to date")},
+	} {
+		if tt.got != "✓ Up to date" {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, "✓ Up to date")
+		}
+	}
+}
+
+func TestANSIStyler(t *testing.T) {
+	s := ansiStyler{theme: themes["dark"]}
+	for _, tt := range []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Success", s.Success("ok"), "\033[32mok\033[0m"},
+		{"Error", s.Error("ok"), "\033[31mok\033[0m"},
+		{"Warning", s.Warning("ok"), "\033[33mok\033[0m"},
+		{"Muted", s.Muted("ok"), "\033[90mok\033[0m"},
+		{"Path", s.Path("ok"), "\033[36mok\033[0m"},
+		{"Bold", s.Bold("ok"), "\033[1mok\033[0m"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestResultsTablePlain(t *testing.T) {
+	withStyler(t, plainStyler{})
+	t.Setenv("COLUMNS", "")
+
+	saved := results
+	results = []result{
+		{Path: "/src/api", Operation: "pull", Status: statusFailed, Error: "unable to pull", Duration: 0.25, branch: "main"},
+		{Path: "/src/web", Operation: "pull", Status: statusSuccess, Detail: "Fast-forwarded", Duration: 1.5, branch: "main"},
+		{Path: "/src/docs", Operation: "pull", Status: statusSkipped, Detail: "Skipped (no remote)", Duration: 0.002, branch: "gh-pages"},
+		{Path: "/src/cli", Operation: "pull", Status: statusSuccess, Detail: "Up to date", Duration: 0.1, branch: "main"},
+	}
+	t.Cleanup(func() { results = saved })
+
+	got := captureStdout(t, writeResultsTable)
+	want := `
+REPOSITORY  BRANCH    RESULT                 DURATION
+Updated (1)
+/src/web    main      ` + iconSuccess + ` Fast-forwarded       1.5s
+
+Already up to date (1)
+/src/cli    main      ` + iconSuccess + ` Up to date           100ms
+
+No remote ` + dash + ` skipped (1)
+/src/docs   gh-pages  ` + iconSkipped + ` Skipped (no remote)  2ms
+
+Failed (1)
+/src/api    main      ` + iconError + ` unable to pull       250ms
+`
+	if got != want {
+		t.Errorf("results table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTablePlainStyle(t *testing.T) {
+	withStyler(t, plainStyler{})
+
+	got := captureStdout(t, func() {
+		writeTable(os.Stdout, []string{"NAME", "PATH"}, [][]string{{"api", "/src/api"}, {"website", "/src/web"}}, 0,
+			func(row, col int, s string) string { return styles.Path(s) })
+	})
+	want := "NAME     PATH\napi      /src/api\nwebsite  /src/web\n"
+	if got != want {
+		t.Errorf("table:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Bring the workspace in line with the manifest in the config file",
+	Long: `Sync makes the workspace match the manifest section of the config file:
+repositories that are missing are cloned and origin remotes that differ are
+pointed at the listed URL. A missing default branch is reported rather than
+created, since it means the remote and the manifest disagree.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifest(cmd.Context(), syncRepo)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(syncCmd)
+	describe(syncCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Clone what is missing and fix remotes", "got sync"},
+			{"Check first", "got doctor && got sync"},
+		},
+	})
+}
+
+func syncRepo(ctx context.Context, r workspaceRepo) error {
+
+	if !isRepository(r.Path) {
+		if err := cloneManifestRepo(ctx, r); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			repoFailed(r.Path, err)
+		} else {
+			repoSucceeded(r.Path, "Cloned %s", r.Remote)
+		}
+		return nil
+	}
+
+	var fixed []string
+	if r.Remote != "" {
+		url, _ := remoteURL(ctx, r.Path)
+		if normalizeRemoteURL(url) != normalizeRemoteURL(r.Remote) {
+			args := []string{"remote", "set-url", "origin", r.Remote}
+			if url == "" {
+				args[1] = "add"
+			}
+			if err := runner.Run(ctx, r.Path, repoCapture(r.Path), args...); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				repoFailed(r.Path, err)
+				return nil
+			}
+			fixed = append(fixed, "Set origin to "+r.Remote)
+		}
+	}
+
+	if problems := manifestDrift(ctx, r); len(problems) > 0 {
+		repoFailed(r.Path, errors.New(strings.Join(problems, "; ")))
+	} else if len(fixed) > 0 {
+		repoSucceeded(r.Path, "%s", strings.Join(fixed, "; "))
+	} else {
+		repoSucceeded(r.Path, "Matches manifest")
+	}
+	return nil
+}
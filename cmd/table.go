@@ -0,0 +1,152 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// columnGap separates table columns.
+const columnGap = "  "
+
+// ellipsis marks where text was cut.
+var ellipsis = "…"
+
+// minColumnWidth is as narrow as writeTable will squeeze a column.
+const minColumnWidth = 8
+
+// terminalWidth returns the width of the terminal on stdout, or of $COLUMNS,
+// or zero when there is no limit.
+func terminalWidth() int {
+	return widthOf(os.Stdout)
+}
+
+// widthOf returns the width of the terminal f is, or of $COLUMNS, or zero
+// when there is no limit.
+func widthOf(f *os.File) int {
+	if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
+
+// writeTable writes rows beneath header as aligned columns. With a width
+// the widest columns are narrowed, truncating their cells, until the table
+// fits. style, if not nil, is applied to each padded cell of rows, so that
+// escape sequences don't throw the alignment out.
+func writeTable(w io.Writer, header []string, rows [][]string, width int, style func(row, col int, s string) string) {
+	widths := columnWidths(header, rows, width)
+	writeRow(w, header, widths, nil)
+	for r, row := range rows {
+		writeRow(w, row, widths, func(col int, s string) string {
+			if style == nil {
+				return s
+			}
+			return style(r, col, s)
+		})
+	}
+}
+
+// columnWidths returns the width of each column of a table of header and
+// rows that fits in width, if there is one.
+func columnWidths(header []string, rows [][]string, width int) []int {
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for col, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[col] {
+				widths[col] = n
+			}
+		}
+	}
+
+	if width > 0 {
+		for total(widths) > width {
+			widest := 0
+			for col := range widths {
+				if widths[col] > widths[widest] {
+					widest = col
+				}
+			}
+			if widths[widest] <= minColumnWidth {
+				break
+			}
+			widths[widest]--
+		}
+	}
+
+	return widths
+}
+
+// writeRow writes a row of a table with columns of widths, applying style,
+// if not nil, to each padded cell.
+func writeRow(w io.Writer, row []string, widths []int, style func(col int, s string) string) {
+	cells := make([]string, len(row))
+	for col, cell := range row {
+		cell = truncate(cell, widths[col])
+		if col < len(row)-1 {
+			cell += strings.Repeat(" ", widths[col]-utf8.RuneCountInString(cell))
+		}
+		if style != nil {
+			cell = style(col, cell)
+		}
+		cells[col] = cell
+	}
+	io.WriteString(w, strings.Join(cells, columnGap)+"\n")
+}
+
+// total returns the width of a table with columns of widths.
+func total(widths []int) int {
+	n := len(columnGap) * (len(widths) - 1)
+	for _, w := range widths {
+		n += w
+	}
+	return n
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	return string([]rune(s)[:width-1]) + ellipsis
+}
+
+// truncateMiddle shortens s to width runes by cutting out its middle, which
+// keeps both the start and the end of a path recognizable.
+func truncateMiddle(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	head := (width - 1) / 2
+	tail := width - 1 - head
+	return string(runes[:head]) + ellipsis + string(runes[len(runes)-tail:])
+}
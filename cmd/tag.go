@@ -0,0 +1,122 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tagName    string
+	tagMessage string
+	tagSign    bool
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag [directory] name",
+	Short: "Tag the current commit of the repositories beneath a directory",
+	Long: `Tag creates the same tag at the current commit of a repository, or with -r
+of every repository beneath a directory, the counterpart of checkout --tag
+for cutting a release across a workspace. Repositories that already have
+the tag are skipped. With -m the tag is annotated.
+
+With -S, or where tag.gpgSign is set, tags are signed, and annotated with
+the tag's name when -m isn't given. The signing configuration of each
+repository is checked first, so that a repository without a signing key,
+or whose signing program isn't installed, fails saying so rather than
+with gpg's error.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 1 {
+			return usageErrorf("tag name argument is required")
+		}
+		tagName = args[len(args)-1]
+		args, err := directoryArgs(args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+		return runOperation(ctx, args[0], tag)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tagCmd)
+	describe(tagCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Tag every repository beneath ~/src as v2.4.0, signed", "got tag -r -S -m \"Release 2.4.0\" ~/src v2.4.0"},
+		},
+	})
+
+	tagCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Recursively tag subdirectories listed")
+	tagCmd.Flags().StringVarP(&tagMessage, "message", "m", "", "Annotate the tag with this message")
+	tagCmd.Flags().BoolVarP(&tagSign, "sign", "S", false, "Sign the tags")
+}
+
+func tag(ctx context.Context, path string) error {
+
+	if !isRepository(path) {
+		if recursive {
+			return nil
+		}
+		return errors.Errorf("[%s] is not a git repository", path)
+	}
+
+	if _, err := gitOutput(ctx, path, "rev-parse", "-q", "--verify", "refs/tags/"+tagName); err == nil {
+		repoSkipped(path, "Skipped (tag exists)")
+		return nil
+	}
+
+	sign := signs(ctx, path, "tag", tagSign)
+	if sign {
+		if err := checkSigning(ctx, path); err != nil {
+			repoFailed(path, err)
+			return nil
+		}
+	}
+
+	args := []string{"tag"}
+	message := tagMessage
+	if sign && message == "" {
+		message = tagName
+	}
+	switch {
+	case tagSign:
+		args = append(args, "-s", "-m", message)
+	case message != "":
+		args = append(args, "-a", "-m", message)
+	}
+	args = append(args, tagName)
+
+	var out bytes.Buffer
+	if err := runner.Run(ctx, path, io.MultiWriter(&out, repoCapture(path)), args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		repoFailed(path, signingError(err, out.Bytes()))
+		return nil
+	}
+
+	head, _ := gitOutput(ctx, path, "rev-parse", "--short", "HEAD")
+	repoSucceeded(path, "Tagged %s as %s", head, tagName)
+	return nil
+}
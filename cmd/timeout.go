@@ -0,0 +1,72 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// repoTimeout is how long an operation may take in one repository, set
+	// by --timeout. Zero means no limit.
+	repoTimeout time.Duration
+
+	// totalTimeout is how long the whole run may take, set by
+	// --total-timeout. Zero means no limit.
+	totalTimeout time.Duration
+
+	cancelRun context.CancelFunc = func() {}
+)
+
+// startRunTimeout gives the context of cmd the deadline of --total-timeout.
+func startRunTimeout(cmd *cobra.Command) {
+	if totalTimeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), totalTimeout)
+	cmd.SetContext(ctx)
+	cancelRun = cancel
+}
+
+// runTimeoutError explains an error that is the run's deadline passing.
+func runTimeoutError(err error) error {
+	if totalTimeout > 0 && errors.Cause(err) == context.DeadlineExceeded {
+		return errors.Errorf("stopped after --total-timeout %s", totalTimeout)
+	}
+	return err
+}
+
+// runWithTimeout runs op in the repository at path within --timeout. An
+// operation that runs out of time fails the repository rather than the run.
+func runWithTimeout(ctx context.Context, path string, op func(ctx context.Context, path string) error) error {
+
+	if repoTimeout <= 0 {
+		return op(ctx, path)
+	}
+
+	repoCtx, cancel := context.WithTimeout(ctx, repoTimeout)
+	defer cancel()
+
+	err := op(repoCtx, path)
+	if ctx.Err() == nil && repoCtx.Err() == context.DeadlineExceeded {
+		repoFailed(path, errors.Errorf("timed out after %s", repoTimeout))
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,248 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// tokenExpiryWarning is how soon before a token expires doctor warns about
+// it.
+const tokenExpiryWarning = 7 * 24 * time.Hour
+
+// tokenInfo is what a forge tells of the token got uses with it.
+type tokenInfo struct {
+	// scopes are the scopes granted to the token, when listed is set. A
+	// fine-grained GitHub token has permissions rather than scopes, and
+	// doesn't list any.
+	scopes  []string
+	listed  bool
+	expires time.Time
+}
+
+// tokenInfo asks f which scopes its token has and when it expires: GitHub
+// lists them in the X-OAuth-Scopes and
+// GitHub-Authentication-Token-Expiration headers of any answer, and GitLab
+// answers /personal_access_tokens/self with them.
+func (f forge) tokenInfo(ctx context.Context) (tokenInfo, error) {
+
+	var info tokenInfo
+	token, _ := f.token()
+	if f.Type == "gitlab" {
+		var self struct {
+			Scopes    []string `json:"scopes"`
+			ExpiresAt string   `json:"expires_at"`
+		}
+		if _, err := f.requestHeader(ctx, f.API+"/personal_access_tokens/self", &self); err != nil {
+			if e, ok := errors.Cause(err).(forgeStatusError); ok && e.code == http.StatusNotFound {
+				// GitLab before 15.5, or a token other than a personal
+				// one, such as a job token.
+				return info, nil
+			}
+			return info, err
+		}
+		info.scopes, info.listed = self.Scopes, true
+		if self.ExpiresAt != "" {
+			info.expires, _ = time.Parse("2006-01-02", self.ExpiresAt)
+		}
+		return info, nil
+	}
+
+	var user forgeUser
+	header, err := f.requestHeader(ctx, f.API+"/user", &user)
+	if err != nil {
+		return info, err
+	}
+	// Fine-grained and app tokens have permissions in place of scopes.
+	fineGrained := strings.HasPrefix(token, "github_pat_") || strings.HasPrefix(token, "ghs_")
+	if _, ok := header["X-Oauth-Scopes"]; ok && !fineGrained {
+		info.listed = true
+		for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				info.scopes = append(info.scopes, scope)
+			}
+		}
+	}
+	if expires := header.Get("GitHub-Authentication-Token-Expiration"); expires != "" {
+		info.expires, _ = time.Parse("2006-01-02 15:04:05 MST", expires)
+	}
+	return info, nil
+}
+
+// requiredScope returns the scope got needs the token of f to have to read
+// pull requests, and the scopes that grant it.
+func (f forge) requiredScope() (string, []string) {
+	if f.Type == "gitlab" {
+		return "read_api", []string{"read_api", "api"}
+	}
+	return "repo", []string{"repo"}
+}
+
+// tokenExpired returns an error if the token of f has expired by now,
+// given info.
+func (f forge) tokenExpired(info tokenInfo, now time.Time) error {
+	if info.expires.IsZero() || now.Before(info.expires) {
+		return nil
+	}
+	_, vars := f.token()
+	return errors.Errorf("%s for %s expired on %s; create a new one at %s", vars, f.Host, info.expires.Format("2006-01-02"), f.tokenSettings())
+}
+
+// missingScope returns an error naming the scope the token of f lacks for
+// got prs, given info, or nil when it has it or the forge doesn't say.
+func (f forge) missingScope(info tokenInfo) error {
+
+	if !info.listed {
+		return nil
+	}
+	scope, granting := f.requiredScope()
+	for _, have := range info.scopes {
+		for _, g := range granting {
+			if have == g {
+				return nil
+			}
+		}
+	}
+
+	_, vars := f.token()
+	granted := "no scopes"
+	if len(info.scopes) > 0 {
+		granted = "only " + strings.Join(info.scopes, ", ")
+	}
+	what := "search the pull requests of private repositories"
+	if f.Type == "gitlab" {
+		what = "read merge requests through the API"
+	}
+	return errors.Errorf("%s for %s lacks the %s scope, which got prs needs to %s (it has %s); add it at %s",
+		vars, f.Host, scope, what, granted, f.tokenSettings())
+}
+
+// tokenSettings returns where tokens for f are managed.
+func (f forge) tokenSettings() string {
+	if f.Type == "gitlab" {
+		return "https://" + f.Host + "/-/user_settings/personal_access_tokens"
+	}
+	return "https://" + f.Host + "/settings/tokens"
+}
+
+// tokenCheck is what checkToken found out about a token.
+type tokenCheck struct {
+	expired, missing error
+}
+
+var (
+	tokenChecksMu sync.Mutex
+	tokenChecks   = map[string]tokenCheck{}
+)
+
+// checkToken returns whether the token of f has expired, and which scope it
+// lacks for got prs, asking the forge once per run. A forge that can't be
+// asked isn't held against the token; the request got goes on to make will
+// fail on its own.
+func (f forge) checkToken(ctx context.Context) (expired, missing error) {
+
+	tokenChecksMu.Lock()
+	defer tokenChecksMu.Unlock()
+	if c, ok := tokenChecks[f.API]; ok {
+		return c.expired, c.missing
+	}
+
+	info, err := f.tokenInfo(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	c := tokenCheck{expired: f.tokenExpired(info, time.Now()), missing: f.missingScope(info)}
+	tokenChecks[f.API] = c
+	return c.expired, c.missing
+}
+
+// tokenForges returns the forges got has a token for: github.com,
+// gitlab.com and those the forges section of the config file lists.
+func tokenForges() []forge {
+
+	var forges []forge
+	seen := map[string]bool{}
+	var configured []forge
+	viper.UnmarshalKey("forges", &configured)
+	for _, c := range configured {
+		if f, ok := forgeOf(c.Host); ok && !seen[strings.ToLower(f.Host)] {
+			seen[strings.ToLower(f.Host)] = true
+			forges = append(forges, f)
+		}
+	}
+	for _, host := range []string{"github.com", "gitlab.com"} {
+		if f, _ := forgeOf(host); !seen[host] {
+			forges = append(forges, f)
+		}
+	}
+
+	var withToken []forge
+	for _, f := range forges {
+		if token, _ := f.token(); token != "" {
+			withToken = append(withToken, f)
+		}
+	}
+	sort.SliceStable(withToken, func(i, j int) bool { return withToken[i].Host < withToken[j].Host })
+	return withToken
+}
+
+// checkTokens reports on the token of every forge got has one for: the
+// scopes it has and when it expires, and what is wrong with it. It returns
+// how many tokens have a problem.
+func checkTokens(ctx context.Context) int {
+
+	problems := 0
+	for _, f := range tokenForges() {
+		_, vars := f.token()
+		info, err := f.tokenInfo(ctx)
+		if err != nil {
+			logger.Error(fmt.Sprintf("%s %s for %s:  unable to check it: %s", styles.Error(iconError), vars, f.Host, err), "forge", f.Host)
+			problems++
+			continue
+		}
+		err = f.tokenExpired(info, time.Now())
+		if err == nil {
+			err = f.missingScope(info)
+		}
+		if err != nil {
+			logger.Error(fmt.Sprintf("%s %s", styles.Error(iconError), err), "forge", f.Host)
+			problems++
+			continue
+		}
+
+		detail := "scopes not listed (fine-grained token)"
+		if info.listed {
+			detail = "scopes " + strings.Join(info.scopes, ", ")
+		}
+		if !info.expires.IsZero() {
+			detail += ", expires " + info.expires.Format("2006-01-02")
+			if time.Until(info.expires) < tokenExpiryWarning {
+				logger.Warn(fmt.Sprintf("%s %s for %s:  %s, soon", styles.Warning(iconWaiting), vars, f.Host, detail), "forge", f.Host)
+				continue
+			}
+		}
+		logger.Info(fmt.Sprintf("%s %s for %s:  %s", styles.Success(iconSuccess), vars, f.Host, detail), "forge", f.Host)
+	}
+	return problems
+}
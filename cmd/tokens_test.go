@@ -0,0 +1,130 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeForge serves the token endpoints of a forge of kind, answering with
+// header and body.
+func fakeForge(t *testing.T, kind string, header map[string]string, body string) forge {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "/user"
+		if kind == "gitlab" {
+			want = "/personal_access_tokens/self"
+		}
+		if r.URL.Path != want {
+			http.NotFound(w, r)
+			return
+		}
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return forge{Host: kind + ".example.com", Type: kind, API: srv.URL}
+}
+
+func TestTokenChecks(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghp_test")
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITLAB_TOKEN", "glpat-test")
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		forge       forge
+		wantExpired string
+		wantMissing string
+	}{
+		{
+			name:  "github with repo",
+			forge: fakeForge(t, "github", map[string]string{"X-OAuth-Scopes": "read:org, repo"}, `{"login":"me"}`),
+		},
+		{
+			name:        "github without repo",
+			forge:       fakeForge(t, "github", map[string]string{"X-OAuth-Scopes": "read:org, gist"}, `{"login":"me"}`),
+			wantMissing: "GITHUB_TOKEN for github.example.com lacks the repo scope, which got prs needs to search the pull requests of private repositories (it has only read:org, gist)",
+		},
+		{
+			name:        "github with no scopes",
+			forge:       fakeForge(t, "github", map[string]string{"X-OAuth-Scopes": ""}, `{"login":"me"}`),
+			wantMissing: "(it has no scopes)",
+		},
+		{
+			name:  "github fine-grained",
+			forge: fakeForge(t, "github", nil, `{"login":"me"}`),
+		},
+		{
+			name: "github expired",
+			forge: fakeForge(t, "github", map[string]string{"X-OAuth-Scopes": "repo",
+				"GitHub-Authentication-Token-Expiration": "2026-10-01 09:30:00 UTC"}, `{"login":"me"}`),
+			wantExpired: "GITHUB_TOKEN for github.example.com expired on 2026-10-01",
+		},
+		{
+			name:  "gitlab with api",
+			forge: fakeForge(t, "gitlab", nil, `{"scopes":["api"],"expires_at":"2027-01-01"}`),
+		},
+		{
+			name:        "gitlab without read_api",
+			forge:       fakeForge(t, "gitlab", nil, `{"scopes":["read_repository"],"expires_at":null}`),
+			wantMissing: "GITLAB_TOKEN for gitlab.example.com lacks the read_api scope",
+		},
+		{
+			name:        "gitlab expired",
+			forge:       fakeForge(t, "gitlab", nil, `{"scopes":["read_api"],"expires_at":"2026-10-14"}`),
+			wantExpired: "expired on 2026-10-14",
+		},
+	}
+
+	for _, tt := range tests {
+		info, err := tt.forge.tokenInfo(context.Background())
+		if err != nil {
+			t.Errorf("%s: tokenInfo: %v", tt.name, err)
+			continue
+		}
+		check := func(what string, err error, want string) {
+			switch {
+			case want == "" && err != nil:
+				t.Errorf("%s: %s = %q, want none", tt.name, what, err)
+			case want != "" && err == nil:
+				t.Errorf("%s: %s = nil, want %q", tt.name, what, want)
+			case want != "" && !strings.Contains(err.Error(), want):
+				t.Errorf("%s: %s = %q, want it to contain %q", tt.name, what, err, want)
+			}
+		}
+		check("tokenExpired", tt.forge.tokenExpired(info, now), tt.wantExpired)
+		check("missingScope", tt.forge.missingScope(info), tt.wantMissing)
+	}
+}
+
+func TestTokenInfoOldGitLab(t *testing.T) {
+	t.Setenv("GITLAB_TOKEN", "glpat-test")
+	// A GitLab without /personal_access_tokens/self doesn't say.
+	f := fakeForge(t, "github", nil, "{}")
+	f.Type = "gitlab"
+	info, err := f.tokenInfo(context.Background())
+	if err != nil || info.listed {
+		t.Errorf("tokenInfo = %+v, %v; want no scopes listed and no error", info, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// toolchainsCmd represents the upgrade-repos command
+var toolchainsCmd = &cobra.Command{
+	Use:     "upgrade-repos directory",
+	Aliases: []string{"toolchains"},
+	Short:   "Report the toolchains the repositories in a workspace require",
+	Long: `Upgrade-repos reads the tool versions declared by every repository beneath a
+directory (.tool-versions, .nvmrc and the go directive of go.mod) and
+reports, per tool, which versions are required and by whom.
+
+Requirements the tools installed on this machine don't satisfy are flagged.
+A go directive is a minimum version; the other files pin a version, where
+"18" is satisfied by any 18.x.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		return toolchains(ctx, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(toolchainsCmd)
+	describe(toolchainsCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Report the toolchains required beneath ~/src", "got upgrade-repos ~/src"},
+		},
+	})
+}
+
+// toolRequirement is a tool version declared by a repository.
+type toolRequirement struct {
+	tool    string
+	version string
+	path    string
+	minimum bool
+}
+
+// toolCommands maps .tool-versions names to the command that reports the
+// installed version, when they differ.
+var toolCommands = map[string]string{
+	"golang": "go",
+	"nodejs": "node",
+	"python": "python3",
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)*`)
+
+func toolchains(ctx context.Context, root string) error {
+
+	var reqs []toolRequirement
+	err := walkRepositories(ctx, root, func(path string) error {
+		reqs = append(reqs, toolRequirements(path)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(reqs) == 0 {
+		fmt.Println("No tool versions declared")
+		return nil
+	}
+
+	byTool := map[string][]toolRequirement{}
+	for _, r := range reqs {
+		byTool[r.tool] = append(byTool[r.tool], r)
+	}
+
+	tools := make([]string, 0, len(byTool))
+	for tool := range byTool {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	for _, tool := range tools {
+		installed := installedVersion(ctx, tool)
+		shown := installed
+		if shown == "" {
+			shown = "not installed"
+		}
+		fmt.Printf("%s (installed: %s)\n", styles.Bold(tool), shown)
+
+		for _, r := range byTool[tool] {
+			icon := styles.Success(iconSuccess)
+			switch ok, known := satisfies(installed, r); {
+			case !known:
+				icon = styles.Muted("?")
+			case !ok:
+				icon = styles.Error(iconError)
+			}
+			fmt.Printf("  %s %-12s %s\n", icon, r.version, styles.Path(displayName(r.path)))
+		}
+	}
+
+	return nil
+}
+
+// toolRequirements returns the tool versions declared in the repository at
+// path.
+func toolRequirements(path string) []toolRequirement {
+
+	var reqs []toolRequirement
+
+	if lines, err := readLines(filepath.Join(path, ".tool-versions")); err == nil {
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				reqs = append(reqs, toolRequirement{tool: toolName(fields[0]), version: fields[1], path: path})
+			}
+		}
+	}
+
+	if lines, err := readLines(filepath.Join(path, ".nvmrc")); err == nil && len(lines) > 0 {
+		reqs = append(reqs, toolRequirement{tool: "node", version: strings.TrimPrefix(lines[0], "v"), path: path})
+	}
+
+	if lines, err := readLines(filepath.Join(path, "go.mod")); err == nil {
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "go" {
+				reqs = append(reqs, toolRequirement{tool: "go", version: fields[1], path: path, minimum: true})
+				break
+			}
+		}
+	}
+
+	return reqs
+}
+
+func toolName(name string) string {
+	if command, ok := toolCommands[name]; ok {
+		return command
+	}
+	return name
+}
+
+// installedVersion returns the version of tool on the PATH, or an empty
+// string when it isn't installed.
+func installedVersion(ctx context.Context, tool string) string {
+
+	args := []string{"--version"}
+	if tool == "go" {
+		args = []string{"env", "GOVERSION"}
+	}
+
+	out, err := exec.CommandContext(ctx, tool, args...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return versionPattern.FindString(string(out))
+}
+
+// satisfies reports whether installed meets r, and whether that could be
+// determined at all; aliases such as lts/* can't be checked.
+func satisfies(installed string, r toolRequirement) (ok, known bool) {
+
+	want := versionPattern.FindString(r.version)
+	if want == "" {
+		return false, false
+	}
+	if installed == "" {
+		return false, true
+	}
+
+	if r.minimum {
+		return compareVersions(installed, want) >= 0, true
+	}
+	return installed == want || strings.HasPrefix(installed, want+"."), true
+}
+
+// compareVersions compares dotted version numbers numerically.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// readLines returns the non-empty, non-comment lines of a file.
+func readLines(name string) ([]string, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,461 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui directory",
+	Short: "Browse the repositories beneath a directory and act on a selection",
+	Long: `Ui shows every repository beneath a directory in a scrollable table with its
+branch and status, and runs pull, fetch or status on the repositories
+selected, showing each one's progress and result in its row.
+
+Move with the arrow keys or j and k, select with space, or a for all, and
+press p to pull, f to fetch or s to refresh the status of the selection,
+or of the repository under the cursor when nothing is selected. q quits,
+stopping whatever is still running. Repositories are worked on --jobs at
+a time, the way a recursive run does.
+
+The dashboard is built into got-ui, a build of got alongside it, so that
+got itself doesn't load the terminal UI library, which queries the terminal
+as it starts. got ui runs got-ui from the directory got is in, or else from
+the PATH.`,
+	ValidArgsFunction: completeDirectory,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if DashboardRunner == nil {
+			return runGotUI()
+		}
+		args, err := directoryArgs(args)
+		if err != nil {
+			return err
+		}
+		if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			return usageErrorf("ui needs a terminal")
+		}
+		return runUI(ctx, args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(uiCmd)
+	describe(uiCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Browse the repositories beneath ~/src", "got ui ~/src"},
+		},
+	})
+}
+
+// DashboardRunner shows d on the terminal until it is quit. It is set by
+// got-ui, and got ui hands over to got-ui when it isn't.
+var DashboardRunner func(ctx context.Context, d *Dashboard) error
+
+// runGotUI runs got-ui with the arguments got was given and exits as it
+// does.
+func runGotUI() error {
+
+	name := "got-ui"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path := ""
+	if self, err := os.Executable(); err == nil {
+		if info, err := os.Stat(filepath.Join(filepath.Dir(self), name)); err == nil && !info.IsDir() {
+			path = filepath.Join(filepath.Dir(self), name)
+		}
+	}
+	if path == "" {
+		var err error
+		if path, err = exec.LookPath(name); err != nil {
+			return errors.New("got ui needs got-ui, next to got or on the PATH (go install github.com/id9051/got/got-ui@latest)")
+		}
+	}
+
+	c := exec.Command(path, os.Args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := c.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitStatusError{code: exitErr.ExitCode()}
+	}
+	return errors.Wrapf(err, "unable to run %s", path)
+}
+
+// uiActions are the operations the dashboard runs, by key.
+var uiActions = map[string]struct {
+	name string
+	verb string
+	op   func(ctx context.Context, path string) error
+}{
+	"p": {"pull", "Pulling", pull},
+	"f": {"fetch", "Fetching", fetch},
+	"s": {"status", "Checking", statusSummary},
+}
+
+// uiRow is a repository in the dashboard.
+type uiRow struct {
+	path     string
+	status   string
+	selected bool
+	running  string
+	result   string
+	failed   bool
+}
+
+// Dashboard is the state of got ui. DashboardRunner drives it: it passes
+// on the keys typed, the size of the terminal and a tick for every frame of
+// the spinner, along with the messages the discovery, status reads and
+// operations the dashboard starts in the background send it, and shows its
+// View. It is only changed from the goroutine of the runner.
+type Dashboard struct {
+	ctx     context.Context
+	send    func(msg interface{})
+	root    string
+	rows    []*uiRow
+	byPath  map[string]*uiRow
+	cursor  int
+	offset  int
+	width   int
+	height  int
+	busy    int
+	frame   int
+	message string
+}
+
+// The messages the background work of the dashboard sends it.
+type (
+	uiFoundMsg      struct{ path string }
+	uiDiscoveredMsg struct{}
+	uiStatusMsg     struct{ path, status string }
+	uiResultMsg     struct{ r result }
+	uiDoneMsg       struct{ path string }
+)
+
+func runUI(ctx context.Context, root string) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The dashboard owns the screen: results come to it instead of the
+	// console, and the log only goes to the log file, if there is one.
+	recursive = true
+	setLogOutput(ioutil.Discard)
+	defer setLogOutput(os.Stderr)
+
+	d := &Dashboard{ctx: ctx, root: root, byPath: map[string]*uiRow{}, message: "Finding repositories" + ellipsis}
+	resultHook = func(r result) { d.send(uiResultMsg{r}) }
+	defer func() { resultHook = nil }()
+
+	err := DashboardRunner(ctx, d)
+	// Quitting stops whatever is still running.
+	cancel()
+	if err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "unable to run the dashboard")
+	}
+	return nil
+}
+
+// Start starts finding the repositories of the dashboard, which it reports
+// through send, as it does the work it starts later on.
+func (d *Dashboard) Start(send func(msg interface{})) {
+	d.send = send
+	go d.discover()
+}
+
+// discover sends the repositories beneath the root of the dashboard and
+// then their status.
+func (d *Dashboard) discover() {
+
+	var paths []string
+	walkRepositories(d.ctx, d.root, func(path string) error {
+		paths = append(paths, path)
+		d.send(uiFoundMsg{path})
+		return nil
+	})
+	d.send(uiDiscoveredMsg{})
+
+	d.each(paths, d.refresh)
+}
+
+// each runs fn on paths, --jobs at a time.
+func (d *Dashboard) each(paths []string, fn func(path string)) {
+	n := jobs
+	if n < 1 {
+		n = 1
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		if d.ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(path string) {
+			defer func() { <-sem; wg.Done() }()
+			fn(path)
+		}(path)
+	}
+	wg.Wait()
+}
+
+// refresh reads and sends the status of the repository at path.
+func (d *Dashboard) refresh(path string) {
+	s := "bare repository"
+	if !git.IsBare(path) {
+		out, err := gitOutput(d.ctx, path, "status", "--porcelain=v2", "--branch")
+		if err != nil {
+			s = "unable to read status"
+		} else {
+			s = git.ParseStatus(out).String()
+		}
+	}
+	d.send(uiStatusMsg{path, s})
+}
+
+// Resize takes the size of the terminal.
+func (d *Dashboard) Resize(width, height int) {
+	d.width, d.height = width, height
+}
+
+// Tick moves the spinners of the repositories being worked on on a frame.
+func (d *Dashboard) Tick() {
+	if d.busy > 0 {
+		d.frame++
+	}
+}
+
+// Update takes a message sent by the background work of the dashboard.
+func (d *Dashboard) Update(msg interface{}) {
+
+	switch msg := msg.(type) {
+	case uiFoundMsg:
+		row := &uiRow{path: msg.path}
+		d.rows = append(d.rows, row)
+		d.byPath[msg.path] = row
+	case uiDiscoveredMsg:
+		d.message = ""
+	case uiStatusMsg:
+		if row, ok := d.byPath[msg.path]; ok {
+			row.status = msg.status
+		}
+	case uiResultMsg:
+		d.record(msg.r)
+	case uiDoneMsg:
+		if row, ok := d.byPath[msg.path]; ok {
+			row.running = ""
+		}
+		d.busy--
+	}
+}
+
+// record takes the result of an operation into the row of its repository.
+func (d *Dashboard) record(r result) {
+	row, ok := d.byPath[r.Path]
+	if !ok {
+		return
+	}
+	switch r.Status {
+	case statusFailed:
+		row.result, row.failed = iconError+" "+r.Error, true
+	case statusSkipped:
+		row.result, row.failed = iconSkipped+" "+r.Detail, false
+	default:
+		row.result, row.failed = iconSuccess+" "+r.Detail, false
+	}
+}
+
+// Key acts on a key typed, named as "q", "up" or "ctrl+c", and reports
+// whether it quits the dashboard.
+func (d *Dashboard) Key(key string) bool {
+	switch key {
+	case "q", "ctrl+c":
+		return true
+	case "up", "k":
+		d.move(-1)
+	case "down", "j":
+		d.move(1)
+	case "pgup":
+		d.move(-d.rowsShown())
+	case "pgdown":
+		d.move(d.rowsShown())
+	case "g", "home":
+		d.move(-len(d.rows))
+	case "G", "end":
+		d.move(len(d.rows))
+	case " ":
+		if d.cursor < len(d.rows) {
+			d.rows[d.cursor].selected = !d.rows[d.cursor].selected
+			d.move(1)
+		}
+	case "a":
+		all := true
+		for _, row := range d.rows {
+			all = all && row.selected
+		}
+		for _, row := range d.rows {
+			row.selected = !all
+		}
+	default:
+		if action, ok := uiActions[key]; ok {
+			d.start(action.name, action.verb, action.op)
+		}
+	}
+	return false
+}
+
+// move moves the cursor by n rows.
+func (d *Dashboard) move(n int) {
+	d.cursor += n
+	if d.cursor >= len(d.rows) {
+		d.cursor = len(d.rows) - 1
+	}
+	if d.cursor < 0 {
+		d.cursor = 0
+	}
+}
+
+// start runs op on the selection, or the repository under the cursor. Only
+// one operation runs at a time, since results are reported under the name
+// of the operation being run.
+func (d *Dashboard) start(name, verb string, op func(ctx context.Context, path string) error) {
+
+	if d.busy > 0 {
+		d.message = fmt.Sprintf("Still running %s; wait for it to finish", operation)
+		return
+	}
+
+	var targets []string
+	for _, row := range d.rows {
+		if row.selected {
+			targets = append(targets, row.path)
+		}
+	}
+	if len(targets) == 0 && d.cursor < len(d.rows) {
+		targets = []string{d.rows[d.cursor].path}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	operation = name
+	d.busy = len(targets)
+	d.message = ""
+	for _, path := range targets {
+		row := d.byPath[path]
+		row.running, row.result, row.failed = verb, "", false
+	}
+
+	go d.each(targets, func(path string) {
+		if err := visit(d.ctx, nil, path, op); err != nil && d.ctx.Err() == nil {
+			d.send(uiResultMsg{result{Path: path, Status: statusFailed, Error: err.Error()}})
+		}
+		d.refresh(path)
+		d.send(uiDoneMsg{path})
+	})
+}
+
+// rowsShown returns how many repositories fit on the screen, between the
+// heading, the table header and the key help.
+func (d *Dashboard) rowsShown() int {
+	h := d.height
+	if h < 6 {
+		h = 24
+	}
+	return h - 3
+}
+
+// View returns the screen of the dashboard.
+func (d *Dashboard) View() string {
+
+	height := d.rowsShown()
+	if d.cursor < d.offset {
+		d.offset = d.cursor
+	}
+	if d.cursor >= d.offset+height {
+		d.offset = d.cursor - height + 1
+	}
+
+	selected := 0
+	for _, row := range d.rows {
+		if row.selected {
+			selected++
+		}
+	}
+
+	var b bytes.Buffer
+	heading := fmt.Sprintf("got ui %s: %d repositories, %d selected", d.root, len(d.rows), selected)
+	if d.message != "" {
+		heading += " " + dash + " " + d.message
+	}
+	b.WriteString(styles.Bold(truncate(heading, d.width)) + "\n")
+
+	header := []string{"", "REPOSITORY", "STATUS", "RESULT"}
+	end := d.offset + height
+	if end > len(d.rows) {
+		end = len(d.rows)
+	}
+	var cells [][]string
+	for i := d.offset; i < end; i++ {
+		row := d.rows[i]
+		mark := "  "
+		if i == d.cursor {
+			mark = "> "
+		}
+		if row.selected {
+			mark += "[x]"
+		} else {
+			mark += "[ ]"
+		}
+		outcome := row.result
+		if row.running != "" {
+			outcome = spinnerFrames[d.frame%len(spinnerFrames)] + " " + row.running + ellipsis
+		}
+		cells = append(cells, []string{mark, displayName(row.path), row.status, outcome})
+	}
+	writeTable(&b, header, cells, d.width, func(r, col int, s string) string {
+		row := d.rows[d.offset+r]
+		switch {
+		case col == 1:
+			return styles.Path(s)
+		case col == 3 && row.running != "":
+			return styles.Muted(s)
+		case col == 3 && row.failed:
+			return styles.Error(s)
+		}
+		return s
+	})
+	b.WriteString(strings.Repeat("\n", height-(end-d.offset)))
+	b.WriteString(styles.Muted("↑/↓ move  space select  a all  p pull  f fetch  s status  q quit"))
+
+	return b.String()
+}
@@ -0,0 +1,145 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify manifest [directory]",
+	Short: "Compare a workspace against a manifest",
+	Long: `Verify compares the repositories beneath a directory, by default the one the
+manifest is in, with those the manifest lists. It reports repositories that
+are missing, repositories the manifest doesn't list, and repositories whose
+origin remote or current branch differ from the manifest.
+
+Each repository is reported as a result, so --json, --porcelain and the other
+output formats give a machine-readable drift report. Verify fails if the
+workspace has drifted at all.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 1 {
+			return usageErrorf("manifest argument is required")
+		}
+		root := filepath.Dir(args[0])
+		if len(args) > 1 {
+			root = args[1]
+		}
+		// The whole workspace is compared, so results are summarized as
+		// for a recursive run.
+		recursive = true
+		return verifyManifest(ctx, args[0], root)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(verifyCmd)
+	describe(verifyCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"Check ~/src against the team manifest", "got verify team.yaml ~/src"},
+			{"Write the drift report as JSON for CI", "got verify --json ~/src/manifest.yaml"},
+		},
+	})
+}
+
+func verifyManifest(ctx context.Context, name, root string) error {
+
+	started := time.Now()
+
+	m, err := loadManifest(name)
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, r := range m.Repositories {
+		listed[r.Path] = true
+	}
+
+	var extra []string
+	err = walkRepositories(ctx, root, func(path string) error {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && !listed[rel] {
+			extra = append(extra, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	drifted := 0
+	for _, r := range m.Repositories {
+		path := filepath.Join(root, r.Path)
+		startRepo(path)
+		if problems := repoDrift(ctx, path, r); len(problems) > 0 {
+			drifted++
+			repoFailed(path, errors.New(strings.Join(problems, "; ")))
+		} else {
+			repoSucceeded(path, "Matches manifest")
+		}
+		endRepo(path)
+	}
+
+	sort.Strings(extra)
+	for _, path := range extra {
+		drifted++
+		repoFailed(path, errors.New("not in manifest"))
+	}
+
+	finishRun(time.Since(started))
+
+	if drifted > 0 {
+		return repositoriesFailed("%d of the repositories in [%s] differ from manifest [%s]", drifted, root, name)
+	}
+	return nil
+}
+
+// repoDrift describes how the repository at path differs from r.
+func repoDrift(ctx context.Context, path string, r workspaceRepo) []string {
+
+	if !isRepository(path) {
+		return []string{"missing"}
+	}
+
+	var problems []string
+	if r.Remote != "" {
+		url, _ := remoteURL(ctx, path)
+		if normalizeRemoteURL(url) != normalizeRemoteURL(r.Remote) {
+			if url == "" {
+				url = "none"
+			}
+			problems = append(problems, fmt.Sprintf("remote is %s, manifest has %s", url, r.Remote))
+		}
+	}
+	if r.Branch != "" {
+		if branch, _ := git.Head(path); branch != r.Branch {
+			problems = append(problems, fmt.Sprintf("branch is %s, manifest has %s", branch, r.Branch))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,473 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/id9051/got/internal/git"
+	"github.com/id9051/got/progress"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+var (
+	failFast bool
+
+	// maxDepth limits how many directories below the root are walked. Zero
+	// means no limit.
+	maxDepth int
+
+	includeBare    bool
+	followSymlinks bool
+)
+
+// isRepository reports whether path is the top of a git working tree, or a
+// bare repository when --include-bare is set.
+func isRepository(path string) bool {
+	return git.IsRepository(path) || (includeBare && git.IsBare(path))
+}
+
+// otherVCSCategory is the heading checkouts of other version control
+// systems are listed under in the results.
+const otherVCSCategory = "Other VCS"
+
+// otherVCSDirs are the directories at the top of checkouts of other version
+// control systems, and the systems' names.
+var otherVCSDirs = []struct{ dir, name string }{
+	{".hg", "Mercurial"},
+	{".svn", "Subversion"},
+}
+
+// otherVCS returns the name of the version control system path is a
+// checkout of, when that isn't git, or else an empty string. A git
+// repository that also holds an .hg or .svn directory, such as one
+// converted from another system, is taken to be a git repository.
+func otherVCS(path string) string {
+	if isRepository(path) {
+		return ""
+	}
+	for _, v := range otherVCSDirs {
+		if info, err := os.Stat(filepath.Join(path, v.dir)); err == nil && info.IsDir() {
+			return v.name
+		}
+	}
+	return ""
+}
+
+// otherVCSDetail is the detail a checkout of the version control system
+// name is skipped with.
+func otherVCSDetail(name string) string {
+	return "Skipped (" + name + " checkout)"
+}
+
+// walkDirectories runs op in every git repository found beneath root.
+// Discovery runs concurrently with op, feeding repositories through a
+// channel as they are found, so work starts straight away and the progress
+// total grows live. When progress is enabled the run is rendered as a
+// single status line and the output of op is held back until the walk
+// completes. With --jobs, op runs in that many repositories at once. The
+// walk stops with ctx.Err() once ctx is cancelled, and with --fail-fast
+// after the first repository that fails.
+func walkDirectories(ctx context.Context, root string, op func(ctx context.Context, path string) error) error {
+	return walkFound(ctx, func(fn func(path string) error) error {
+		return walkCheckouts(ctx, root, true, fn)
+	}, op)
+}
+
+// walkFound runs op, as walkDirectories does, in every repository discover
+// passes to its callback. With --time-budget, no repository is started once
+// the budget is used up; the ones left are recorded for got retry.
+func walkFound(ctx context.Context, discover func(fn func(path string) error) error, op func(ctx context.Context, path string) error) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var deadline time.Time
+	if timeBudget > 0 {
+		deadline = time.Now().Add(timeBudget)
+		discover = byPriority(discover)
+	}
+
+	t := progress.New()
+	for _, o := range progressObservers {
+		t.Subscribe(o)
+	}
+
+	var p *progressTracker
+	if showProgress {
+		p = newProgressTracker()
+		t.Subscribe(p)
+		progressActive = true
+		setLogOutput(&gitOutputBuffer)
+		p.start()
+	}
+
+	repos := make(chan string, 64)
+	discovered := make(chan error, 1)
+	go func() {
+		defer close(repos)
+		discovered <- discover(func(path string) error {
+			t.Found(path)
+			select {
+			case repos <- path:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		t.Discovered()
+	}()
+
+	var (
+		errMu sync.Mutex
+		err   error
+		wg    sync.WaitGroup
+	)
+	var remaining []string
+	slots := make(chan struct{}, jobs)
+	for path := range repos {
+		slots <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			<-slots
+			remaining = append(remaining, path)
+			continue
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			before := failureCount()
+			verr := visit(ctx, t, path, op)
+			if verr == nil && failFast && failureCount() > before {
+				verr = repositoriesFailed("stopping after [%s] failed (--fail-fast)", path)
+			}
+			if verr != nil {
+				errMu.Lock()
+				if err == nil {
+					err = verr
+				}
+				errMu.Unlock()
+				cancel()
+			}
+		}(path)
+	}
+	wg.Wait()
+	for range repos {
+		// Let discovery see the cancellation and finish.
+	}
+	if derr := <-discovered; err == nil {
+		err = derr
+	}
+
+	if p != nil {
+		p.stop()
+		progressActive = false
+		setLogOutput(os.Stderr)
+		flushGitOutput()
+	}
+
+	if timeBudget > 0 && ctx.Err() == nil {
+		recordRemaining(remaining)
+	}
+
+	return err
+}
+
+// visit runs op in a single repository, keeping the progress line and the
+// repository's result up to date. t may be nil. Operations in the same
+// repository are serialized across got processes with lockRepo. Repositories
+// matching a skip pattern or kept out by an override, checkouts of other
+// version control systems, and with --changed-since those without upstream
+// changes, are skipped. Only commands that change repositories take the lock,
+// and a repository that can't be locked fails on its own. The preRepo and
+// postRepo hooks run around op.
+func visit(ctx context.Context, t *progress.Tracker, path string, op func(ctx context.Context, path string) error) error {
+
+	if t != nil {
+		t.Begin(path)
+		defer t.End(path)
+	}
+
+	if vcs := otherVCS(path); vcs != "" {
+		startRepo(path)
+		defer endRepo(path)
+		repoSkipped(path, "%s", otherVCSDetail(vcs))
+		return nil
+	}
+
+	if isRepository(path) {
+		if pattern, ok := skipPattern(path); ok {
+			startRepo(path)
+			defer endRepo(path)
+			repoSkipped(path, "Skipped (matches %s)", pattern)
+			return nil
+		}
+		if reason, ok := overridden(path); ok {
+			startRepo(path)
+			defer endRepo(path)
+			repoSkipped(path, "Skipped (%s)", reason)
+			return nil
+		}
+	}
+
+	if isRepository(path) && filteringChanged() && !upstreamChanged(ctx, path) {
+		startRepo(path)
+		defer endRepo(path)
+		repoSkipped(path, "Skipped (no upstream changes since %s)", changedSinceFlag)
+		return nil
+	}
+
+	if isRepository(path) && (running == nil || running.Mutating) {
+		unlock, err := lockRepo(ctx, path, func(holder string) {
+			if t != nil {
+				t.Wait(path, holder)
+			}
+			if !progressActive {
+				logger.Info(fmt.Sprintf("%s [%s]:  %s", styles.Muted(iconWaiting), styles.Path(displayName(path)), styles.Muted("Waiting for "+holder)),
+					"path", path, "holder", holder)
+			}
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			startRepo(path)
+			defer endRepo(path)
+			repoFailed(path, err)
+			return nil
+		}
+		defer unlock()
+		if t != nil {
+			t.Wait(path, "")
+		}
+	}
+
+	startRepo(path)
+	defer endRepo(path)
+
+	if !isRepository(path) {
+		return runWithTimeout(ctx, path, op)
+	}
+	if !preRepoHook(ctx, path) {
+		return nil
+	}
+	err := measureFetched(ctx, path, func() error { return runWithTimeout(ctx, path, op) })
+	postRepoHook(ctx, path)
+	return err
+}
+
+// runOperation runs op in the directory given on the command line, or with
+// -r in every repository beneath it, and then reports on the run as a whole.
+// Settings of the group path is in apply to the run.
+func runOperation(ctx context.Context, path string, op func(ctx context.Context, path string) error) error {
+
+	started := time.Now()
+	runGroups = resolveGroups()
+	defer func() { runGroups = nil }()
+	applyGroup(path)
+
+	var err error
+	if recursive {
+		err = walkDirectories(ctx, path, op)
+	} else {
+		err = visit(ctx, nil, path, op)
+	}
+	saveHostLimits()
+
+	finishRun(time.Since(started))
+	return err
+}
+
+// walkRepositories calls fn for every repository beneath root. With
+// --follow-symlinks, symlinked directories are walked too, under their link
+// path; a link into a tree that is already being walked, including one that
+// loops back on itself, is not followed again, and a repository reachable
+// by several paths is only visited once. With an include list, repositories
+// that don't match it are passed over. A root of @name walks the paths of
+// that group in turn.
+func walkRepositories(ctx context.Context, root string, fn func(path string) error) error {
+	return walkCheckouts(ctx, root, false, fn)
+}
+
+// walkCheckouts walks root as walkRepositories does. Checkouts of other
+// version control systems, such as Mercurial or Subversion, aren't
+// descended into, and with withOtherVCS fn is called for them as well, so that
+// a run can report them.
+func walkCheckouts(ctx context.Context, root string, withOtherVCS bool, fn func(path string) error) error {
+
+	if name, _, ok, err := groupArg(root); err != nil {
+		return err
+	} else if ok {
+		// Paths of a group can overlap, such as ~/src and ~/src/acme/*.
+		done := map[string]bool{}
+		for _, path := range currentGroups().paths[strings.ToLower(name)] {
+			err := walkCheckouts(ctx, path, withOtherVCS, func(path string) error {
+				if done[path] {
+					return nil
+				}
+				done[path] = true
+				return fn(path)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	hidden := viper.GetBool("hidden")
+	scanRoot = root
+
+	var trees []string
+	seen := map[string]bool{}
+	if followSymlinks {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			trees = append(trees, real)
+		}
+	}
+
+	var walkTree func(dir, display string) error
+	walkTree = func(dir, display string) error {
+
+		return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// Report paths beneath a followed link under the link itself.
+			if dir != display {
+				rel, _ := filepath.Rel(dir, path)
+				path = filepath.Join(display, rel)
+			}
+
+			// Usually usually happens when a director is deleted. If exists when filepath.Walk
+			// is called but then the pull removes it. So we get a "No such file or directory"
+			// error. We're returning nil so that processing continues.
+			if err != nil {
+				logger.Warn(errors.Wrapf(err, "error walking filepath [%s]", path).Error(), "path", path)
+				return nil
+			}
+
+			if followSymlinks && info.Mode()&os.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return nil
+				}
+				if ti, err := os.Stat(target); err != nil || !ti.IsDir() || within(target, trees) {
+					return nil
+				}
+				if maxDepth > 0 && depth(root, path) > maxDepth {
+					return nil
+				}
+				trees = append(trees, target)
+				return walkTree(target, path)
+			}
+
+			if !info.IsDir() {
+				return nil
+			} else if filepath.Base(path) == ".git" {
+				return filepath.SkipDir
+			}
+
+			if maxDepth > 0 && depth(root, path) > maxDepth {
+				return filepath.SkipDir
+			}
+
+			if !hidden && path != root && strings.HasPrefix(filepath.Base(path), ".") {
+				return filepath.SkipDir
+			}
+
+			// There is nothing to find inside a bare repository.
+			if git.IsBare(path) {
+				if includeBare && included(path) && originIncluded(ctx, path) && !visited(seen, path) {
+					if err := fn(path); err != nil {
+						return err
+					}
+				}
+				return filepath.SkipDir
+			}
+
+			if !isRepository(path) {
+				if otherVCS(path) == "" {
+					return nil
+				}
+				if withOtherVCS && included(path) && !visited(seen, path) {
+					if err := fn(path); err != nil {
+						return err
+					}
+				}
+				return filepath.SkipDir
+			}
+
+			if !included(path) || !originIncluded(ctx, path) || visited(seen, path) {
+				return nil
+			}
+
+			return fn(path)
+		})
+	}
+
+	return walkTree(root, root)
+}
+
+// visited records path in seen by its real location when following
+// symlinks, reporting whether it was already there.
+func visited(seen map[string]bool, path string) bool {
+	if !followSymlinks {
+		return false
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	if seen[real] {
+		return true
+	}
+	seen[real] = true
+	return false
+}
+
+// within reports whether path is one of trees or lies beneath one of them.
+func within(path string, trees []string) bool {
+	sep := string(filepath.Separator)
+	for _, tree := range trees {
+		// A tree at the root of a file system or drive, such as / or
+		// C:\, already ends in a separator.
+		if samePath(path, tree) || strings.HasPrefix(foldPath(path), strings.TrimSuffix(foldPath(tree), sep)+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// depth returns how many directories path is below root.
+func depth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
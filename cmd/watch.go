@@ -0,0 +1,290 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// defaultWatchInterval is how often watch runs its operation without
+// --interval.
+const defaultWatchInterval = 15 * time.Minute
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch operation [flags] directory [--interval duration]",
+	Short: "Run an operation over and over on an interval",
+	Long: `Watch keeps running a got command, such as fetch -r ~/src, every --interval
+(15m unless given), until it is interrupted. After each run it shows the
+summary of the run and what changed since the previous one: repositories
+that started or stopped failing, whose result changed, or that appeared or
+went away, followed by the results of the run.
+
+Everything but --interval is handed to the command as it is, so its flags
+and got's global flags can be given anywhere. Each run writes --json for
+watch to read, so the other output formats can't be used.`,
+	DisableFlagParsing: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		interval, args, err := watchArgs(args)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+			return cmd.Help()
+		}
+		return watch(ctx, interval, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	describe(watchCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Fetch every repository beneath ~/src every 15 minutes", "got watch fetch -r ~/src --interval 15m"},
+			{"Keep an eye on the status of a workspace", "got watch status -r ~/src --interval 1m"},
+		},
+	})
+}
+
+// watchArgs takes --interval out of args, which watch leaves unparsed for
+// the command it runs.
+func watchArgs(args []string) (time.Duration, []string, error) {
+
+	interval := defaultWatchInterval
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		value, ok := "", false
+		switch {
+		case args[i] == "--interval":
+			if i+1 == len(args) {
+				return 0, nil, usageErrorf("--interval needs a duration")
+			}
+			value, ok = args[i+1], true
+			i++
+		case strings.HasPrefix(args[i], "--interval="):
+			value, ok = strings.TrimPrefix(args[i], "--interval="), true
+		}
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return 0, nil, usageErrorf("invalid --interval [%s], expected a duration such as 15m", value)
+		}
+		interval = d
+	}
+	return interval, rest, nil
+}
+
+// watchRun is what watch keeps of one run of its command.
+type watchRun struct {
+	started time.Time
+	results map[string]result
+	summary summary
+	stderr  string
+}
+
+func watch(ctx context.Context, interval time.Duration, args []string) error {
+
+	self, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "unable to find the got executable")
+	}
+
+	var previous *watchRun
+	for n := 1; ; n++ {
+		run, err := watchOnce(ctx, self, args)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && previous == nil {
+			return err
+		}
+
+		if isTerminal(os.Stdout) && !ciMode() {
+			fmt.Print("\x1b[H\x1b[2J")
+		}
+		fmt.Println(styles.Bold(fmt.Sprintf("Every %s: got %s", interval, strings.Join(args, " "))))
+		if err != nil {
+			fmt.Printf("Run %d at %s failed: %v\n", n, time.Now().Format("15:04:05"), err)
+		} else {
+			showWatchRun(n, run, previous)
+			previous = run
+		}
+
+		next := time.Now().Add(interval)
+		fmt.Println(styles.Muted(fmt.Sprintf("Next run at %s", next.Format("15:04:05"))))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// watchOnce runs the command once, reading its results from --json.
+func watchOnce(ctx context.Context, self string, args []string) (*watchRun, error) {
+
+	run := &watchRun{started: time.Now(), results: map[string]result{}}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, self, append(append([]string(nil), args...), "--json")...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	run.stderr = strings.TrimSpace(stderr.String())
+
+	// Failed repositories make got exit with exitFailures, which is still a
+	// complete run.
+	if exit, ok := err.(*exec.ExitError); err != nil && (!ok || exit.ExitCode() != exitFailures) {
+		if run.stderr != "" {
+			return nil, errors.New(strings.TrimPrefix(lastLine(run.stderr), "Error: "))
+		}
+		return nil, errors.Wrap(err, "unable to run got")
+	}
+
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var kind struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &kind) != nil {
+			continue
+		}
+		switch kind.Type {
+		case "repository":
+			var r result
+			if json.Unmarshal(scanner.Bytes(), &r) == nil {
+				run.results[r.Path] = r
+			}
+		case "summary":
+			json.Unmarshal(scanner.Bytes(), &run.summary)
+		}
+	}
+	return run, nil
+}
+
+// lastLine returns the last line of s.
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\n")+1:]
+}
+
+// showWatchRun shows the summary of run, what changed since previous, if
+// there was one, and the results of run.
+func showWatchRun(n int, run, previous *watchRun) {
+
+	s := run.summary
+	fmt.Printf("Run %d at %s: %d repositories, %d succeeded, %d skipped, %d failed (%s)\n\n",
+		n, run.started.Format("15:04:05"), s.Total, s.Succeeded, s.Skipped, s.Failed,
+		(time.Duration(s.Duration * float64(time.Second))).Truncate(time.Millisecond))
+
+	if previous != nil {
+		changes := watchChanges(previous, run)
+		if len(changes) == 0 {
+			fmt.Println(styles.Muted("No changes since the previous run"))
+		} else {
+			fmt.Println("Changes since the previous run:")
+			for _, c := range changes {
+				fmt.Println("  " + c)
+			}
+		}
+		fmt.Println()
+	}
+
+	paths := make([]string, 0, len(run.results))
+	for path := range run.results {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	rows := make([][]string, len(paths))
+	for i, path := range paths {
+		r := run.results[path]
+		rows[i] = []string{displayName(path), r.Status, watchDetail(r)}
+	}
+	writeTable(os.Stdout, []string{"REPOSITORY", "STATUS", "DETAIL"}, rows, terminalWidth(), func(row, col int, s string) string {
+		switch {
+		case col == 0:
+			return styles.Path(s)
+		case col == 1 && run.results[paths[row]].Status == statusFailed:
+			return styles.Error(s)
+		}
+		return s
+	})
+	if run.stderr != "" {
+		fmt.Println()
+		fmt.Println(styles.Muted(run.stderr))
+	}
+}
+
+// watchDetail returns the detail of r, or its error.
+func watchDetail(r result) string {
+	if r.Status == statusFailed {
+		return r.Error
+	}
+	return r.Detail
+}
+
+// watchChanges describes how the results of run differ from those of
+// previous, by repository.
+func watchChanges(previous, run *watchRun) []string {
+
+	var paths []string
+	for path := range run.results {
+		paths = append(paths, path)
+	}
+	for path := range previous.results {
+		if _, ok := run.results[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var changes []string
+	for _, path := range paths {
+		before, was := previous.results[path]
+		now, is := run.results[path]
+		label := "[" + styles.Path(displayName(path)) + "]"
+		switch {
+		case !was:
+			changes = append(changes, fmt.Sprintf("+ %s new: %s", label, watchDetail(now)))
+		case !is:
+			changes = append(changes, fmt.Sprintf("- %s gone", label))
+		case now.Status == statusFailed && before.Status != statusFailed:
+			changes = append(changes, fmt.Sprintf("%s %s now failing: %s", styles.Error(iconError), label, now.Error))
+		case before.Status == statusFailed && now.Status != statusFailed:
+			changes = append(changes, fmt.Sprintf("%s %s recovered: %s", styles.Success(iconSuccess), label, watchDetail(now)))
+		case watchDetail(before) != watchDetail(now):
+			changes = append(changes, fmt.Sprintf("~ %s %s → %s", label, watchDetail(before), watchDetail(now)))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,164 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// webhookTimeout is how long posting to the webhook may take.
+const webhookTimeout = 10 * time.Second
+
+// webhookReport is what the generic webhook is sent after a recursive run:
+// the summary of --json, how many repositories a pull updated, and the
+// repositories that failed.
+type webhookReport struct {
+	summary
+	Updated  int              `json:"updated"`
+	Failures []webhookFailure `json:"failures,omitempty"`
+}
+
+// webhookFailure is a repository that failed, as the webhook is told.
+type webhookFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// sendWebhook posts the summary of a recursive run to the webhook in the
+// config file, or given with --webhook:
+//
+//	webhook: https://hooks.slack.com/services/...
+//	webhookFormat: slack
+//
+// The format is slack or teams for a message in a channel, and generic for
+// the webhookReport as JSON. Without one it is read from the URL's host,
+// and is generic for hosts other than Slack's and Teams'. A webhook that
+// can't be reached is warned about without failing the run. The URL of a
+// webhook is as good as a password to it, so only its host is ever logged.
+func sendWebhook(s summary) {
+
+	target := viper.GetString("webhook")
+	if target == "" {
+		return
+	}
+
+	report := webhookReport{summary: s}
+	resultsMu.Lock()
+	for _, r := range results {
+		switch {
+		case r.Status == statusFailed:
+			report.Failures = append(report.Failures, webhookFailure{Path: r.Path, Error: r.Error})
+		case r.Status == statusSuccess && strings.HasPrefix(r.Detail, "Updated"):
+			report.Updated++
+		}
+	}
+	resultsMu.Unlock()
+
+	format, err := webhookFormat(target)
+	if err == nil {
+		err = postWebhook(target, format, report)
+	}
+	if err != nil {
+		msg := "unable to notify webhook"
+		host := ""
+		if u, perr := url.Parse(target); perr == nil && u.Host != "" {
+			host = u.Host
+			msg += " at " + host
+		}
+		logger.Warn(fmt.Sprintf("%s: %s", msg, err), "webhookHost", host)
+	}
+}
+
+// webhookFormat returns the format to post to target in.
+func webhookFormat(target string) (string, error) {
+
+	switch format := viper.GetString("webhookFormat"); format {
+	case "generic", "slack", "teams":
+		return format, nil
+	case "":
+	default:
+		return "", errors.Errorf("unknown webhookFormat [%s], expected generic, slack or teams", format)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", errors.New("invalid webhook URL")
+	}
+	switch host := strings.ToLower(u.Hostname()); {
+	case host == "hooks.slack.com":
+		return "slack", nil
+	case strings.HasSuffix(host, ".webhook.office.com") || strings.HasSuffix(host, ".logic.azure.com"):
+		return "teams", nil
+	}
+	return "generic", nil
+}
+
+// postWebhook posts report to target in format.
+func postWebhook(target, format string, report webhookReport) error {
+
+	var body interface{} = report
+	if format != "generic" {
+		// Slack and Teams both show the text of a message posted as
+		// {"text": ...}.
+		body = map[string]string{"text": webhookText(report)}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// The error names the URL it was posting to; the reason is enough.
+		if uerr, ok := err.(*url.Error); ok {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			return errors.Errorf("%s: %s", resp.Status, text)
+		}
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// webhookText renders report as a message for a chat channel.
+func webhookText(report webhookReport) string {
+
+	s := report.summary
+	text := fmt.Sprintf("got %s: %d repositories, %d updated, %d succeeded, %d skipped, %d failed (%s)",
+		s.Operation, s.Total, report.Updated, s.Succeeded, s.Skipped, s.Failed,
+		(time.Duration(s.Duration * float64(time.Second))).Truncate(time.Second))
+	for _, f := range report.Failures {
+		text += fmt.Sprintf("\n%s %s: %s", iconError, displayName(f.Path), f.Error)
+	}
+	return text
+}
@@ -0,0 +1,245 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	worktreePath string
+	worktreePR   int
+)
+
+// worktreeCmd represents the worktree command
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree",
+	Short: "Manage linked worktrees of a repository",
+	Long: `Worktree adds, lists and removes linked worktrees of a repository.
+
+New worktrees are created next to the repository in <repository>.worktrees/,
+one directory per branch, so that a recursive run over the parent directory
+picks them up alongside the repository itself.`,
+}
+
+var worktreeAddCmd = &cobra.Command{
+	Use:               "add repository [branch]",
+	Short:             "Create a worktree for a branch or pull request",
+	ValidArgsFunction: completeRepositoryBranch,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 1 {
+			return usageErrorf("repository argument is required")
+		}
+		args[0] = repoArg(args[0])
+		branch := ""
+		if len(args) > 1 {
+			branch = args[1]
+		}
+		return worktreeAdd(ctx, args[0], branch)
+	},
+}
+
+var worktreeListCmd = &cobra.Command{
+	Use:               "list repository",
+	Short:             "List the worktrees of a repository",
+	ValidArgsFunction: completeRepositoryBranch,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 1 {
+			return usageErrorf("repository argument is required")
+		}
+		args[0] = repoArg(args[0])
+		if !isRepository(args[0]) {
+			return errors.Errorf("[%s] is not a git repository", args[0])
+		}
+		return runner.Run(ctx, args[0], os.Stdout, "worktree", "list")
+	},
+}
+
+var worktreeRemoveCmd = &cobra.Command{
+	Use:               "remove repository branch|path",
+	Short:             "Remove a worktree of a repository",
+	ValidArgsFunction: completeRepositoryBranch,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) < 2 {
+			return usageErrorf("repository and worktree arguments are required")
+		}
+		args[0] = repoArg(args[0])
+		return worktreeRemove(ctx, args[0], args[1])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(worktreeCmd)
+	worktreeCmd.AddCommand(worktreeAddCmd, worktreeListCmd, worktreeRemoveCmd)
+	describe(worktreeCmd, commandInfo{
+		Mutating: true,
+	})
+	describe(worktreeAddCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Create a worktree for the feature/login branch", "got worktree add ~/src/app feature/login"},
+			{"Create a worktree for pull request 42", "got worktree add --pr 42 ~/src/app"},
+		},
+	})
+	describe(worktreeListCmd, commandInfo{
+		Mutating: false,
+		Examples: []example{
+			{"List the worktrees of a repository", "got worktree list ~/src/app"},
+		},
+	})
+	describe(worktreeRemoveCmd, commandInfo{
+		Mutating: true,
+		Examples: []example{
+			{"Remove the worktree of the feature/login branch", "got worktree remove ~/src/app feature/login"},
+		},
+	})
+
+	worktreeAddCmd.Flags().StringVar(&worktreePath, "path", "", "Create the worktree here instead of in <repository>.worktrees/")
+	worktreeAddCmd.Flags().IntVar(&worktreePR, "pr", 0, "Check out the head of this pull request, or GitLab merge request, from origin")
+}
+
+func worktreeAdd(ctx context.Context, repo, branch string) error {
+
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		return errors.Wrapf(err, "unable to resolve [%s]", repo)
+	}
+	if !isRepository(repo) {
+		return errors.Errorf("[%s] is not a git repository", repo)
+	}
+
+	if worktreePR > 0 {
+		if branch == "" {
+			branch = fmt.Sprintf("pr-%d", worktreePR)
+		}
+		ref, err := pullRequestRef(ctx, repo, worktreePR)
+		if err != nil {
+			return err
+		}
+		spec := fmt.Sprintf("%s:%s", ref, branch)
+		if out, err := gitCommand(ctx, repo, "fetch", "origin", spec).CombinedOutput(); err != nil {
+			return errors.Errorf("[%s] unable to fetch pull request %d: %s", repo, worktreePR, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if branch == "" {
+		return usageErrorf("branch argument or --pr is required")
+	}
+
+	path := worktreePath
+	if path == "" {
+		path = filepath.Join(repo+".worktrees", strings.Replace(branch, "/", "-", -1))
+	}
+
+	if out, err := gitCommand(ctx, repo, "worktree", "add", path, branch).CombinedOutput(); err != nil {
+		return errors.Errorf("[%s] unable to add worktree: %s", repo, strings.TrimSpace(string(out)))
+	}
+
+	repoSucceeded(path, "Added worktree for %s", branch)
+	return nil
+}
+
+// pullRequestRef returns the ref the forge of repo's origin keeps the head of
+// pull request number under: pull/N/head on GitHub and merge-requests/N/head
+// on GitLab. Other remotes have no such ref that got knows of.
+func pullRequestRef(ctx context.Context, repo string, number int) (string, error) {
+
+	remote, err := remoteURL(ctx, repo)
+	if err != nil || remote == "" {
+		return "", errors.Errorf("[%s] has no origin to fetch pull request %d from", repo, number)
+	}
+	host := strings.Split(normalizeRemoteURL(remote), "/")[0]
+	f, ok := forgeOf(host)
+	if !ok {
+		return "", errors.Errorf("[%s] origin %s is not a known GitHub or GitLab forge, --pr needs one (add it to forges in the config file)", repo, host)
+	}
+	if f.Type == "gitlab" {
+		return fmt.Sprintf("merge-requests/%d/head", number), nil
+	}
+	return fmt.Sprintf("pull/%d/head", number), nil
+}
+
+func worktreeRemove(ctx context.Context, repo, target string) error {
+
+	if !isRepository(repo) {
+		return errors.Errorf("[%s] is not a git repository", repo)
+	}
+
+	worktrees, err := listWorktrees(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	abs, _ := filepath.Abs(target)
+	for path, branch := range worktrees {
+		if path != abs && branch != target {
+			continue
+		}
+		if out, err := gitCommand(ctx, repo, "worktree", "remove", path).CombinedOutput(); err != nil {
+			return errors.Errorf("[%s] unable to remove worktree: %s", path, strings.TrimSpace(string(out)))
+		}
+		// Tidy up the standard location once its last worktree is gone.
+		if parent := filepath.Dir(path); strings.HasSuffix(parent, ".worktrees") {
+			os.Remove(parent)
+		}
+		repoSucceeded(path, "Removed worktree")
+		return nil
+	}
+
+	return errors.Errorf("[%s] has no worktree for [%s]", repo, target)
+}
+
+// listWorktrees returns the linked worktrees of repo, mapping each path to
+// the branch checked out in it. The main working tree is not included.
+func listWorktrees(ctx context.Context, repo string) (map[string]string, error) {
+
+	out, err := gitOutput(ctx, repo, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, errors.Wrapf(err, "[%s] unable to list worktrees", repo)
+	}
+
+	worktrees := map[string]string{}
+	first := true
+	path := ""
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			path = strings.TrimPrefix(line, "worktree ")
+			if !first {
+				worktrees[path] = ""
+			}
+			first = false
+		case strings.HasPrefix(line, "branch ") && path != "":
+			if _, ok := worktrees[path]; ok {
+				worktrees[path] = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+			}
+		}
+	}
+
+	return worktrees, nil
+}
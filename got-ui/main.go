@@ -0,0 +1,80 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command got-ui is got with the dashboard of got ui built in. got itself
+// leaves it out, as the terminal UI library queries the terminal whenever
+// it is loaded, and got ui runs got-ui in its place.
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/id9051/got/cmd"
+)
+
+// model drives a cmd.Dashboard as a bubbletea model.
+type model struct {
+	d    *cmd.Dashboard
+	send func(tea.Msg)
+}
+
+// tickMsg is the tick of a spinner frame.
+type tickMsg struct{}
+
+func tick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m *model) Init() tea.Cmd {
+	m.d.Start(func(msg interface{}) { m.send(msg) })
+	return tick()
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.d.Resize(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		if m.d.Key(msg.String()) {
+			return m, tea.Quit
+		}
+	case tickMsg:
+		m.d.Tick()
+		return m, tick()
+	default:
+		m.d.Update(msg)
+	}
+	return m, nil
+}
+
+func (m *model) View() string {
+	return m.d.View()
+}
+
+// runDashboard runs d full screen until it is quit.
+func runDashboard(ctx context.Context, d *cmd.Dashboard) error {
+	m := &model{d: d}
+	p := tea.NewProgram(m, tea.WithContext(ctx), tea.WithAltScreen())
+	m.send = p.Send
+	_, err := p.Run()
+	return err
+}
+
+func main() {
+	cmd.DashboardRunner = runDashboard
+	cmd.Execute()
+}
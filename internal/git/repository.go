@@ -0,0 +1,138 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package git locates git repositories on disk and reads their state.
+package git
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const gitdirPrefix = "gitdir:"
+
+// IsRepository reports whether path is the top of a git working tree. The
+// working tree's .git may be a directory, or, for linked worktrees and
+// submodules, a file pointing at the git directory.
+func IsRepository(path string) bool {
+	_, err := GitDir(path)
+	return err == nil
+}
+
+// GitDir returns the git directory of the working tree at path, following
+// a .git file's gitdir: pointer when there is one.
+func GitDir(path string) (string, error) {
+
+	dotgit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotgit)
+	if err != nil {
+		return "", errors.Wrapf(err, "[%s] is not a git repository", path)
+	}
+
+	if info.IsDir() {
+		return dotgit, nil
+	}
+
+	dir, err := readGitdirFile(dotgit)
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", errors.Errorf("[%s] points at missing git directory [%s]", dotgit, dir)
+	}
+
+	return dir, nil
+}
+
+// readGitdirFile parses a .git file of the form "gitdir: <path>". Relative
+// paths are relative to the directory holding the file.
+func readGitdirFile(name string) (string, error) {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read [%s]", name)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrapf(err, "unable to read [%s]", name)
+	}
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, gitdirPrefix) {
+		return "", errors.Errorf("[%s] is not a gitdir file", name)
+	}
+
+	dir := strings.TrimSpace(strings.TrimPrefix(line, gitdirPrefix))
+	if dir == "" {
+		return "", errors.Errorf("[%s] has an empty gitdir", name)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(name), dir)
+	}
+
+	return filepath.Clean(dir), nil
+}
+
+// IsBare reports whether path is a bare repository: a git directory with
+// no working tree, as used for mirrors and server-side copies.
+func IsBare(path string) bool {
+
+	if info, err := os.Stat(filepath.Join(path, "HEAD")); err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	for _, dir := range []string{"objects", "refs"} {
+		if info, err := os.Stat(filepath.Join(path, dir)); err != nil || !info.IsDir() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Head returns the branch checked out in the repository at path, read
+// straight from HEAD without running git. A detached HEAD is returned as
+// the abbreviated commit it points at.
+func Head(path string) (string, error) {
+
+	dir := path
+	if !IsBare(path) {
+		var err error
+		if dir, err = GitDir(path); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "HEAD"))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read HEAD of [%s]", path)
+	}
+
+	head := strings.TrimSpace(string(data))
+	if strings.HasPrefix(head, "ref: ") {
+		return strings.TrimPrefix(strings.TrimPrefix(head, "ref: "), "refs/heads/"), nil
+	}
+	if len(head) > 7 {
+		head = head[:7]
+	}
+	return head, nil
+}
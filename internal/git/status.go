@@ -0,0 +1,148 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Status is the state of a working tree as reported by
+// git status --porcelain=v2 --branch.
+type Status struct {
+	Branch   string
+	Detached bool
+	Upstream string
+	Ahead    int
+	Behind   int
+
+	Staged     int
+	Modified   int
+	Untracked  int
+	Conflicted int
+}
+
+// ParseStatus parses the output of git status --porcelain=v2 --branch.
+// Lines it doesn't recognize are ignored.
+func ParseStatus(out string) Status {
+
+	var s Status
+	var oid string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "#":
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "branch.oid":
+				oid = fields[2]
+			case "branch.head":
+				if fields[2] == "(detached)" {
+					s.Detached = true
+				} else {
+					s.Branch = fields[2]
+				}
+			case "branch.upstream":
+				s.Upstream = fields[2]
+			case "branch.ab":
+				s.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+				if len(fields) > 3 {
+					s.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+				}
+			}
+		case "1", "2":
+			if fields[1][0] != '.' {
+				s.Staged++
+			}
+			if len(fields[1]) > 1 && fields[1][1] != '.' {
+				s.Modified++
+			}
+		case "u":
+			s.Conflicted++
+		case "?":
+			s.Untracked++
+		}
+	}
+
+	// A detached HEAD is known by the commit it is at.
+	if s.Detached {
+		s.Branch = oid
+		if len(oid) > 7 {
+			s.Branch = oid[:7]
+		}
+	}
+
+	return s
+}
+
+// Clean reports whether the working tree has no changes, untracked files
+// included.
+func (s Status) Clean() bool {
+	return s.Staged == 0 && s.Modified == 0 && s.Untracked == 0 && s.Conflicted == 0
+}
+
+// String summarizes s on one line, such as
+// "main (ahead 2, behind 1): 1 staged, 3 modified".
+func (s Status) String() string {
+
+	head := s.Branch
+	if s.Detached {
+		head = "detached at " + s.Branch
+	}
+
+	var tracking []string
+	switch {
+	case s.Detached:
+	case s.Upstream == "":
+		tracking = append(tracking, "no upstream")
+	default:
+		if s.Ahead > 0 {
+			tracking = append(tracking, fmt.Sprintf("ahead %d", s.Ahead))
+		}
+		if s.Behind > 0 {
+			tracking = append(tracking, fmt.Sprintf("behind %d", s.Behind))
+		}
+	}
+	if len(tracking) > 0 {
+		head += " (" + strings.Join(tracking, ", ") + ")"
+	}
+
+	if s.Clean() {
+		return head + ": clean"
+	}
+
+	var changes []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{s.Conflicted, "conflicted"},
+		{s.Staged, "staged"},
+		{s.Modified, "modified"},
+		{s.Untracked, "untracked"},
+	} {
+		if c.n > 0 {
+			changes = append(changes, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	return head + ": " + strings.Join(changes, ", ")
+}
@@ -0,0 +1,158 @@
+// Copyright © 2017 Jeff Durham <jeffrey.durham@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progress tracks a run across many repositories as a stream of
+// events and a snapshot of where the run stands, for whatever wants to show
+// it: got's own progress line, a TUI, an editor plugin or a status endpoint.
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType says what happened in an Event.
+type EventType int
+
+const (
+	// Found is sent when a repository is discovered, growing the total.
+	Found EventType = iota
+	// Discovered is sent once discovery is over and the total is final.
+	Discovered
+	// Began is sent when the operation starts in a repository.
+	Began
+	// Waiting is sent while a repository waits for another process to
+	// release it, and again with an empty Holder once the wait is over.
+	Waiting
+	// Ended is sent when the operation is done in a repository.
+	Ended
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Found:
+		return "found"
+	case Discovered:
+		return "discovered"
+	case Began:
+		return "began"
+	case Waiting:
+		return "waiting"
+	case Ended:
+		return "ended"
+	}
+	return "unknown"
+}
+
+// Event is a change in the progress of a run.
+type Event struct {
+	Type EventType
+	// Path is the repository the event is about; it is empty for
+	// Discovered.
+	Path string
+	// Holder describes the process a Waiting repository is waiting for.
+	Holder string
+	Time   time.Time
+}
+
+// Snapshot is where a run stands.
+type Snapshot struct {
+	// Total is the number of repositories found so far, and Complete
+	// whether discovery is over and Total final.
+	Total    int
+	Complete bool
+	// Done is the number of repositories the operation has finished in.
+	Done int
+	// Current is the repository the operation is running in, and Holder
+	// what it is waiting for, if anything.
+	Current string
+	Holder  string
+	Started time.Time
+}
+
+// Observer is told of every event of a run along with the snapshot just
+// after it. Observers are called one at a time, in order, and must not
+// call back into the Tracker.
+type Observer interface {
+	Observe(e Event, s Snapshot)
+}
+
+// ObserverFunc adapts a function to an Observer.
+type ObserverFunc func(e Event, s Snapshot)
+
+// Observe calls f(e, s).
+func (f ObserverFunc) Observe(e Event, s Snapshot) { f(e, s) }
+
+// Tracker keeps the Snapshot of a run up to date and passes every change on
+// to its observers. A Tracker is safe for concurrent use.
+type Tracker struct {
+	mu        sync.Mutex
+	state     Snapshot
+	observers []Observer
+}
+
+// New returns a Tracker for a run starting now.
+func New() *Tracker {
+	return &Tracker{state: Snapshot{Started: time.Now()}}
+}
+
+// Subscribe adds o to the observers of t.
+func (t *Tracker) Subscribe(o Observer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observers = append(t.observers, o)
+}
+
+// Snapshot returns where the run stands.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// Found records that the repository at path was discovered.
+func (t *Tracker) Found(path string) {
+	t.update(Event{Type: Found, Path: path}, func(s *Snapshot) { s.Total++ })
+}
+
+// Discovered records that discovery is over.
+func (t *Tracker) Discovered() {
+	t.update(Event{Type: Discovered}, func(s *Snapshot) { s.Complete = true })
+}
+
+// Begin records that the operation started in the repository at path.
+func (t *Tracker) Begin(path string) {
+	t.update(Event{Type: Began, Path: path}, func(s *Snapshot) { s.Current, s.Holder = path, "" })
+}
+
+// Wait records that the repository at path is waiting for holder, or with
+// an empty holder that it has stopped waiting.
+func (t *Tracker) Wait(path, holder string) {
+	t.update(Event{Type: Waiting, Path: path, Holder: holder}, func(s *Snapshot) { s.Current, s.Holder = path, holder })
+}
+
+// End records that the operation is done in the repository at path.
+func (t *Tracker) End(path string) {
+	t.update(Event{Type: Ended, Path: path}, func(s *Snapshot) { s.Done++ })
+}
+
+func (t *Tracker) update(e Event, change func(s *Snapshot)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.Time = time.Now()
+	change(&t.state)
+	for _, o := range t.observers {
+		o.Observe(e, t.state)
+	}
+}